@@ -0,0 +1,67 @@
+//go:build !windows
+
+package integration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mikesmithgh/git-prompt-string/pkg/git"
+)
+
+// passthroughGit is a git wrapper that runs git, so that both paths of
+// BenchmarkCollect pay for the wrapper.
+const passthroughGit = `#!/bin/sh
+exec %s "$@"
+`
+
+// noPorcelainV2Git is a git wrapper that fails git status --porcelain=v2 like
+// versions of git before 2.11, so that Collect runs each command instead.
+const noPorcelainV2Git = `#!/bin/sh
+for arg; do
+	case "$arg" in
+	--porcelain=v2)
+		echo "error: option 'porcelain' takes no value" >&2
+		exit 129
+		;;
+	esac
+done
+exec %s "$@"
+`
+
+// BenchmarkCollect measures Collect on the fixtures, once with git status
+// --porcelain=v2 and once with the individual git commands that Collect falls
+// back to when porcelain v2 is not supported.
+func BenchmarkCollect(b *testing.B) {
+	paths := []struct {
+		name   string
+		binDir string
+	}{
+		{"porcelain_v2", writeGitWrapper(b, "passthroughbin", passthroughGit)},
+		{"each_command", writeGitWrapper(b, "noporcelainv2bin", noPorcelainV2Git)},
+	}
+
+	for _, dir := range []string{"clean", "merge_conflict", "no_upstream_remote", "sparse_merge_conflict", "bare"} {
+		ctx := git.WithDir(context.Background(), filepath.Join(tmpDir, "testdata", dir))
+		for _, path := range paths {
+			b.Run(dir+"/"+path.name, func(b *testing.B) {
+				b.Setenv("PATH", path.binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+				for i := 0; i < b.N; i++ {
+					// Collect caches what it reads in g, so each iteration
+					// starts from a repository that is not collected yet
+					b.StopTimer()
+					g, _, err := git.RevParse(ctx)
+					if err != nil {
+						b.Fatal(err)
+					}
+					b.StartTimer()
+					if err := g.Collect(ctx); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package integration
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigPrint(t *testing.T) {
+	tests := []struct {
+		golden  string
+		dir     string
+		input   []string
+		environ []string
+	}{
+		{"print_none", "norepo", []string{"--config=NONE", "config", "print"}, nil},
+		{"print_flags", "clean", []string{"config", "print", "--config=../configs/color_overrides.toml", "--count-changes", "--color-dirty=red"}, []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=prompt-string.prompt-suffix", "GIT_CONFIG_VALUE_0= stash"}},
+		{"print_match", "clean", []string{"--config=../configs/match.toml", "config", "print"}, nil},
+		{"print_env", "clean", []string{"--config=../configs/match.toml", "config", "print", "--color-dirty=red"}, []string{"GIT_PROMPT_STRING_PROMPT_SUFFIX= env", "GIT_PROMPT_STRING_COLOR_DIRTY=blue"}},
+	}
+
+	for _, test := range tests {
+		cmd := exec.Command(builtBinaryPath, test.input...)
+		cmd.Dir = filepath.Join(tmpDir, "testdata", test.dir)
+		cmd.Env = append(os.Environ(), test.environ...)
+		result, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Errorf("Unexpected error: %s: %s", err, result)
+		}
+		goldenPath := filepath.Join("testdata", "config", test.golden+".golden")
+		if *update {
+			if err := os.WriteFile(goldenPath, result, 0o644); err != nil { //nolint:gosec // golden files are not sensitive
+				t.Fatalf("failed to update golden file: %s", err)
+			}
+		}
+		expected, err := os.ReadFile(goldenPath)
+		if err != nil {
+			t.Fatalf("failed to read golden file: %s", err)
+		}
+		if string(result) != string(expected) {
+			t.Errorf("%s does not match %s\nexpected:\n%s\ngot:\n%s", test.input, goldenPath, expected, result)
+		}
+	}
+}
+
+func TestConfigInit(t *testing.T) {
+	xdgConfigHome := t.TempDir()
+	cfgPath := filepath.Join(xdgConfigHome, "git-prompt-string", "config.toml")
+
+	cmd := exec.Command(builtBinaryPath, "config", "init")
+	cmd.Env = append(os.Environ(), "XDG_CONFIG_HOME="+xdgConfigHome, "GIT_PROMPT_STRING_CONFIG=")
+	result, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s: %s", err, result)
+	}
+	if expected := "created " + cfgPath + "\n"; string(result) != expected {
+		t.Errorf("expected:\n%q, \ngot:\n%q", expected, result)
+	}
+
+	goldenPath := filepath.Join("testdata", "config", "init.golden")
+	actual, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatalf("failed to read config: %s", err)
+	}
+	if *update {
+		if err := os.WriteFile(goldenPath, actual, 0o644); err != nil { //nolint:gosec // golden files are not sensitive
+			t.Fatalf("failed to update golden file: %s", err)
+		}
+	}
+	expected, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %s", err)
+	}
+	if string(actual) != string(expected) {
+		t.Errorf("%s does not match %s\nexpected:\n%s\ngot:\n%s", cfgPath, goldenPath, expected, actual)
+	}
+
+	// the commented default configuration is valid and changes nothing
+	cmd = exec.Command(builtBinaryPath, "config", "check", cfgPath)
+	if result, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("Unexpected error: %s: %s", err, result)
+	}
+
+	cmd = exec.Command(builtBinaryPath, "config", "init")
+	cmd.Env = append(os.Environ(), "XDG_CONFIG_HOME="+xdgConfigHome, "GIT_PROMPT_STRING_CONFIG=")
+	result, err = cmd.CombinedOutput()
+	if err == nil || err.Error() != "exit status 1" {
+		t.Errorf("Expected error: exit status 1, got: %v", err)
+	}
+	expected = []byte("\x1b[31m git-prompt-string error(config init): \"" + cfgPath + " already exists\"\x1b[0m")
+	if string(result) != string(expected) {
+		t.Errorf("expected:\n%q, \ngot:\n%q", expected, result)
+	}
+}
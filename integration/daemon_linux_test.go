@@ -0,0 +1,122 @@
+package integration
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemon(t *testing.T) {
+	runtimeDir := t.TempDir()
+	socketPath := filepath.Join(runtimeDir, "git-prompt-string", "daemon.sock")
+	env := append(os.Environ(), "XDG_RUNTIME_DIR="+runtimeDir)
+
+	daemon := exec.Command(builtBinaryPath, "daemon", "--daemon-idle-timeout=2s", "--daemon-evict-timeout=1s")
+	daemon.Env = env
+	if err := daemon.Start(); err != nil {
+		t.Fatalf("failed to start daemon: %s", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = daemon.Wait()
+		close(exited)
+	}()
+	t.Cleanup(func() {
+		_ = daemon.Process.Kill()
+		<-exited
+	})
+	waitFor(t, "the daemon to listen", func() bool {
+		_, err := os.Stat(socketPath)
+		return err == nil
+	})
+
+	cmd := exec.Command(builtBinaryPath, "daemon")
+	cmd.Env = env
+	result, err := cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(result), "already listening") {
+		t.Errorf("expected a second daemon to fail, got %v: %s", err, result)
+	}
+
+	repoDir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=git-prompt-string", "GIT_AUTHOR_EMAIL=git-prompt-string@example.com",
+			"GIT_COMMITTER_NAME=git-prompt-string", "GIT_COMMITTER_EMAIL=git-prompt-string@example.com",
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s: %s", strings.Join(args, " "), err, out)
+		}
+	}
+	git("init", "--quiet", "--initial-branch=main")
+	git("commit", "--quiet", "--allow-empty", "--message=initial")
+
+	// git fails for the client, so the prompt can only be answered by the daemon
+	binDir := t.TempDir()
+	err = os.WriteFile(filepath.Join(binDir, "git"), []byte("#!/bin/sh\nexit 1\n"), 0o755) //nolint:gosec // the script must be executable
+	if err != nil {
+		t.Fatalf("failed to write git: %s", err)
+	}
+	prompt := func(environ []string) string {
+		t.Helper()
+		cmd := exec.Command(builtBinaryPath, "--config=NONE")
+		cmd.Dir = repoDir
+		cmd.Env = environ
+		result, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s: %s", err, result)
+		}
+		return string(result)
+	}
+	assertPrompt := func(step string) {
+		t.Helper()
+		expected := prompt(os.Environ())
+		var actual string
+		waitFor(t, step, func() bool {
+			actual = prompt(append(env, "PATH="+binDir))
+			return actual == expected
+		})
+	}
+
+	assertPrompt("clean")
+	if err := os.Mkdir(filepath.Join(repoDir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	assertPrompt("new directory")
+	if err := os.WriteFile(filepath.Join(repoDir, "sub", "file"), []byte("file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	assertPrompt("untracked file in new directory")
+	git("add", "sub/file")
+	assertPrompt("staged file")
+	git("commit", "--quiet", "--message=file")
+	assertPrompt("commit")
+	git("checkout", "--quiet", "--detach")
+	git("tag", "v1.0.0")
+	assertPrompt("tag")
+
+	select {
+	case <-exited:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("expected the daemon to stop once idle")
+	}
+	if _, err := os.Stat(socketPath); !os.IsNotExist(err) {
+		t.Errorf("expected the socket to be removed, got %v", err)
+	}
+}
+
+// waitFor waits for condition to become true, since the daemon is notified
+// of changes asynchronously.
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !condition(); time.Sleep(20 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package integration
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnvEval(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+	branch := `it's \ "$x" $(y)`
+	cmd := exec.Command(bash, "-c", `eval "$("$0" --config=NONE --output=env --shell=bash)" && printf '%s|%s|%s' "$GPS_UPSTREAM_BRANCH" "$GPS_BRANCH" "$GPS_DIRTY"`, builtBinaryPath)
+	cmd.Dir = filepath.Join(tmpDir, "testdata", "no_upstream_remote")
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=branch.main.merge", "GIT_CONFIG_VALUE_0=refs/heads/"+branch)
+	result, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s: %s", err, result)
+	}
+	expected := branch + "|main|0"
+	if string(result) != expected {
+		t.Errorf("expected:\n%q, \ngot:\n%q", expected, result)
+	}
+}
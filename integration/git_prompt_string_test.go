@@ -64,6 +64,178 @@ func TestGitPromptString(t *testing.T) {
 		{"conflict_diverged", []string{"--config=NONE", "--color-disabled", "--diverged-format=ahead by %d behind by %d"}, " \ue0a0 main ahead by 1 behind by 1", nil, nil},
 		{"no_upstream_remote", []string{"--config=NONE", "--color-disabled", "--no-upstream-remote-format= upstream=[repo: %s branch: %s]"}, " \ue0a0 main upstream=[repo: mikesmithgh/test branch: main]", nil, nil},
 
+		// format
+		{"clean", []string{"--config=NONE", "--format={{.PromptPrefix}}{{.BranchInfo}}{{.BranchStatus}}"}, " \ue0a0 main", nil, nil},
+		{"conflict_diverged", []string{"--config=NONE", "--format={{.Ahead}}{{.Behind}} {{.Branch}}"}, "11 main", nil, nil},
+		{"conflict_diverged", []string{"--config=NONE", "--format={{paint .Color .Branch}}{{prefix \" \" .UpstreamRemote}}"}, "\x1b[33mmain\x1b[0m origin", nil, nil},
+		{"rebase_i", []string{"--config=NONE", "--format={{.MergeState}} {{.Step}}/{{.Total}}{{when .Conflict \"!\"}}"}, "REBASE-i 1/1", nil, nil},
+		{"merge_conflict", []string{"--config=NONE", "--format={{.MergeState}}{{when .Conflict \"!\"}}{{when .Dirty \"*\"}}"}, "MERGING!*", nil, nil},
+		{"tag", []string{"--config=NONE", "--color-disabled", "--format={{.Tag}} {{.ShortSha}}"}, "v1.0.0 24afc95", nil, nil},
+		{"clean/.git", []string{"--config=NONE", "--color-disabled", "--format={{.Branch}} {{.ShortSha}}"}, "main 24afc95", nil, nil},
+		{"bare", []string{"--config=NONE", "--color-disabled", "--format={{when .Bare \"BARE\"}}{{when .Dirty \"*\"}}"}, "BARE", nil, nil},
+		{"sparse", []string{"--config=NONE", "--format={{color .Color}}{{.PromptBranch}}{{when .Sparse \" sparse\"}}{{reset}}"}, "\x1b[32mmain sparse\x1b[0m", nil, nil},
+		{"clean", []string{"--config=NONE", "--format={{color \"nope\"}}"}, "\x1b[31m git-prompt-string error(format): \"template: format:1:2: executing \\\"format\\\" at \\<color \\\"nope\\\"\\>: error calling color: color nope not found\"\x1b[0m", nil, errors.New("exit status 1")},
+
+		// segment colors
+		{"sparse_merge_conflict", []string{"--config=NONE", "--color-branch=cyan", "--color-merge-state=bright-red", "--color-ahead-behind=yellow", "--color-dirty-marker=white"}, "\x1b[31m \ue0a0 \x1b[0m\x1b[36mmain\x1b[0m\x1b[31m|SPARSE\x1b[0m\x1b[91m|MERGING|CONFLICT\x1b[0m\x1b[31m \x1b[0m\x1b[37m*\x1b[0m\x1b[31m\x1b[0m\x1b[33m↕ ↑[1] ↓[1]\x1b[0m\x1b[31m\x1b[0m", nil, nil},
+		{"sparse", []string{"--config=NONE", "--color-prefix=blue", "--color-sparse=white", "--prompt-suffix= >", "--color-suffix=bg:#000000"}, "\x1b[32m\x1b[0m\x1b[34m \ue0a0 \x1b[0m\x1b[32mmain\x1b[0m\x1b[37m|SPARSE\x1b[0m\x1b[32m\x1b[0m\x1b[48;2;0;0;0m >\x1b[0m\x1b[32m\x1b[0m", nil, nil},
+		{"clean", []string{"--config=NONE", "--color-disabled", "--color-branch=cyan", "--color-prefix=blue"}, " \ue0a0 main", nil, nil},
+		{"clean", []string{"--config=NONE", "--color-branch=nope"}, "\x1b[31m git-prompt-string error(prompt color): \"color nope not found\"\x1b[0m", nil, errors.New("exit status 1")},
+
+		// change counts
+		{"dirty", []string{"--config=NONE", "--count-changes"}, "\x1b[31m \ue0a0 main ~1\x1b[0m", nil, nil},
+		{"untracked", []string{"--config=NONE", "--count-changes"}, "\x1b[35m \ue0a0 main ?1\x1b[0m", nil, nil},
+		{"merge", []string{"--config=NONE", "--count-changes", "--color-disabled"}, " \ue0a0 main|MERGING +1 ?1 ↕ ↑[1] ↓[1]", nil, nil},
+		{"merge_conflict", []string{"--config=NONE", "--count-changes", "--color-disabled", "--conflicted-format=conflicts:%v"}, " \ue0a0 main|MERGING|CONFLICT conflicts:1 ↕ ↑[1] ↓[1]", nil, nil},
+		{"revert", []string{"--config=NONE", "--count-changes", "--color-disabled", "--staged-format=staged:%v"}, " \ue0a0 main|REVERTING staged:1 ↕ ↑[2] ↓[1]", nil, nil},
+		{"clean", []string{"--config=NONE", "--count-changes", "--color-disabled"}, " \ue0a0 main", nil, nil},
+		{"merge", []string{"--config=NONE", "--color-disabled", "--format={{.Staged}} {{.Modified}} {{.Deleted}} {{.Renamed}} {{.UntrackedCount}} {{.Conflicted}}"}, "1 0 0 0 1 0", nil, nil},
+
+		// stash
+		{"stash", []string{"--config=NONE"}, "\x1b[32m \ue0a0 main ≡2\x1b[0m", nil, nil},
+		{"stash", []string{"--config=NONE", "--color-stash=cyan"}, "\x1b[32m \ue0a0 main\x1b[0m\x1b[36m ≡2\x1b[0m\x1b[32m\x1b[0m", nil, nil},
+		{"stash", []string{"--config=NONE", "--color-disabled", "--stash-format= stash:%v"}, " \ue0a0 main stash:2", nil, nil},
+		{"stash", []string{"--config=NONE", "--color-disabled", "--stash-format="}, " \ue0a0 main", nil, nil},
+		{"stash", []string{"--config=NONE", "--format={{.Stash}}"}, "2", nil, nil},
+
+		// text styles
+		{"dirty", []string{"--config=NONE", "--color-dirty=bold bright-red bg:#202020"}, "\x1b[1m\x1b[91m\x1b[48;2;32;32;32m \ue0a0 main *\x1b[0m", nil, nil},
+		{"clean", []string{"--config=NONE", "--color-branch=italic underline", "--color-prefix=dim"}, "\x1b[32m\x1b[0m\x1b[2m \ue0a0 \x1b[0m\x1b[32m\x1b[0m\x1b[3m\x1b[4mmain\x1b[0m\x1b[32m\x1b[0m", nil, nil},
+		{"clean", []string{"--config=NONE", "--color-clean=reverse blink strikethrough hidden"}, "\x1b[7m\x1b[5m\x1b[9m\x1b[8m \ue0a0 main\x1b[0m", nil, nil},
+		{"clean", []string{"--config=NONE", "--format={{color \"bold green\"}}{{.Branch}}{{color \"no-bold no-dim no-italic no-underline no-blink no-reverse no-hidden no-strikethrough\"}}"}, "\x1b[1m\x1b[32mmain\x1b[22m\x1b[22m\x1b[23m\x1b[24m\x1b[25m\x1b[27m\x1b[28m\x1b[29m", nil, nil},
+		{"clean", []string{"--config=NONE", "--color-branch=bold brite-red"}, "\x1b[31m git-prompt-string error(prompt color): \"color brite-red not found\"\x1b[0m", nil, errors.New("exit status 1")},
+
+		// shell
+		{"dirty", []string{"--config=NONE", "--shell=bash"}, "\\[\x1b[31m\\] \ue0a0 main *\\[\x1b[0m\\]", nil, nil},
+		{"dirty", []string{"--config=NONE", "--shell=readline"}, "\x01\x1b[31m\x02 \ue0a0 main *\x01\x1b[0m\x02", nil, nil},
+		{"dirty", []string{"--config=NONE", "--shell=zsh"}, "%{\x1b[31m%} \ue0a0 main *%{\x1b[0m%}", nil, nil},
+		{"dirty", []string{"--config=NONE", "--shell=tcsh"}, "%{\x1b[31m%} \ue0a0 main *%{\x1b[0m%}", nil, nil},
+		{"dirty", []string{"--config=NONE", "--shell=fish"}, "\x1b[31m \ue0a0 main *\x1b[0m", nil, nil},
+		{"dirty", []string{"--config=NONE", "--shell=powershell"}, "\x1b[31m \ue0a0 main *\x1b[0m", nil, nil},
+		{"dirty", []string{"--config=NONE", "--shell=raw"}, "\x1b[31m \ue0a0 main *\x1b[0m", nil, nil},
+		{"dirty", []string{"--config=NONE", "--shell=zsh", "--color-disabled"}, " \ue0a0 main *", nil, nil},
+		{"percent_branch", []string{"--config=NONE", "--shell=zsh"}, "%{\x1b[32m%} \ue0a0 fe%%at%{\x1b[0m%}", nil, nil},
+		{"percent_branch", []string{"--config=NONE", "--shell=zsh", "--format={{.Branch}} {{.PromptBranch}} {{.UpstreamBranch}}"}, "fe%%at fe%%at fe%%at", nil, nil},
+		{"percent_branch", []string{"--config=NONE", "--shell=zsh", "--powerline", "--color-disabled"}, " \ue0a0 fe%%at \ue0b0", nil, nil},
+		{"percent_branch", []string{"--config=NONE", "--shell=bash", "--color-disabled"}, " \ue0a0 fe%at", nil, nil},
+		{"no_upstream_remote", []string{"--config=NONE", "--shell=zsh"}, "%{\x1b[90m%} \ue0a0 main → mikesmithgh/test/fix%%1%{\x1b[0m%}", []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=branch.main.merge", "GIT_CONFIG_VALUE_0=refs/heads/fix%1"}, nil},
+		{"no_upstream_remote", []string{"--config=NONE", "--shell=tcsh", "--color-disabled"}, " \ue0a0 main → mikesmithgh/test/fix%%1", []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=branch.main.merge", "GIT_CONFIG_VALUE_0=refs/heads/fix%1"}, nil},
+		{"no_upstream_remote", []string{"--config=NONE", "--shell=bash"}, "\\[\x1b[90m\\] \ue0a0 main → mikesmithgh/test/fix\\\\1%1\\[\x1b[0m\\]", []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=branch.main.merge", "GIT_CONFIG_VALUE_0=refs/heads/fix\\1%1"}, nil},
+		{"no_upstream_remote", []string{"--config=NONE", "--shell=readline", "--color-disabled"}, " \ue0a0 main → mikesmithgh/test/fix\\1%1", []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=branch.main.merge", "GIT_CONFIG_VALUE_0=refs/heads/fix\\1%1"}, nil},
+		{"no_upstream_remote", []string{"--config=NONE", "--shell=zsh", "--format={{.UpstreamBranch}}"}, "fix%%1", []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=branch.main.merge", "GIT_CONFIG_VALUE_0=refs/heads/fix%1"}, nil},
+		{"stash", []string{"--config=NONE", "--shell=zsh", "--color-stash=cyan"}, "%{\x1b[32m%} \ue0a0 main%{\x1b[0m%}%{\x1b[36m%} ≡2%{\x1b[0m%}%{\x1b[32m%}%{\x1b[0m%}", nil, nil},
+		{"clean", []string{"--config=NONE", "--shell=bash", "--format={{paint \"cyan\" .Branch}}"}, "\\[\x1b[36m\\]main\\[\x1b[0m\\]", nil, nil},
+		{"clean", []string{"--config=NONE", "--shell=cmd"}, "\x1b[31m git-prompt-string error(shell): \"shell cmd not supported\"\x1b[0m", nil, errors.New("exit status 1")},
+
+		// tmux
+		{"clean", []string{"--config=NONE", "--output=tmux"}, "#[fg=green] \ue0a0 main#[default]", nil, nil},
+		{"dirty", []string{"--config=NONE", "--output=tmux", "--shell=bash"}, "#[fg=red] \ue0a0 main *#[default]", nil, nil},
+		{"tag", []string{"--config=NONE", "--output=tmux"}, "#[fg=brightblack] \ue0a0 (v1.0.0)#[default]", nil, nil},
+		{"stash", []string{"--config=NONE", "--output=tmux", "--color-stash=bold bg:#202020"}, "#[fg=green] \ue0a0 main#[default]#[bold,bg=#202020] ≡2#[default]#[fg=green]#[default]", nil, nil},
+		{"untracked", []string{"--config=../configs/color_overrides.toml", "--output=tmux", "--color-depth=16"}, "#[fg=brightred,bg=cyan] \ue0a0 main *#[default]", nil, nil},
+		{"no_upstream_remote", []string{"--config=NONE", "--output=tmux"}, "#[fg=brightblack] \ue0a0 main → mikesmithgh/test/fix##1#[default]", []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=branch.main.merge", "GIT_CONFIG_VALUE_0=refs/heads/fix#1"}, nil},
+		{"clean", []string{"--config=NONE", "--output=tmux", "--color-disabled"}, " \ue0a0 main", nil, nil},
+		{"clean", []string{"--config=NONE", "--output=nope"}, "\x1b[31m git-prompt-string error(output): \"output nope not supported\\, expected one of prompt\\, tmux\\, env\"\x1b[0m", nil, errors.New("exit status 1")},
+
+		// env
+		{"rebase_i", []string{"--config=NONE", "--output=env"}, `GPS_BRANCH=main
+GPS_TAG=''
+GPS_SHA=b69e688
+GPS_STATE=REBASE-i
+GPS_STEP=1
+GPS_TOTAL=1
+GPS_AHEAD=0
+GPS_BEHIND=0
+GPS_DIRTY=0
+GPS_UNTRACKED=0
+GPS_CONFLICT=0
+GPS_BARE=0
+GPS_SHALLOW=0
+GPS_SPARSE=0
+GPS_GIT_DIR=$TMPDIR/testdata/rebase_i/.git
+GPS_UPSTREAM_REMOTE=''
+GPS_UPSTREAM_BRANCH=''
+GPS_STAGED_COUNT=0
+GPS_MODIFIED_COUNT=0
+GPS_DELETED_COUNT=0
+GPS_RENAMED_COUNT=0
+GPS_UNTRACKED_COUNT=0
+GPS_CONFLICTED_COUNT=0
+GPS_STASH_COUNT=0
+GPS_TIMED_OUT=0
+`, nil, nil},
+		{"no_upstream_remote", []string{"--config=NONE", "--output=env", "--shell=fish"}, `set -g GPS_BRANCH main
+set -g GPS_TAG ''
+set -g GPS_SHA 24afc95
+set -g GPS_STATE ''
+set -g GPS_STEP ''
+set -g GPS_TOTAL ''
+set -g GPS_AHEAD 0
+set -g GPS_BEHIND 0
+set -g GPS_DIRTY 0
+set -g GPS_UNTRACKED 0
+set -g GPS_CONFLICT 0
+set -g GPS_BARE 0
+set -g GPS_SHALLOW 0
+set -g GPS_SPARSE 0
+set -g GPS_GIT_DIR $TMPDIR/testdata/no_upstream_remote/.git
+set -g GPS_UPSTREAM_REMOTE mikesmithgh/test
+set -g GPS_UPSTREAM_BRANCH 'it\'s \\ $x'
+set -g GPS_STAGED_COUNT 0
+set -g GPS_MODIFIED_COUNT 0
+set -g GPS_DELETED_COUNT 0
+set -g GPS_RENAMED_COUNT 0
+set -g GPS_UNTRACKED_COUNT 0
+set -g GPS_CONFLICTED_COUNT 0
+set -g GPS_STASH_COUNT 0
+set -g GPS_TIMED_OUT 0
+`, []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=branch.main.merge", `GIT_CONFIG_VALUE_0=refs/heads/it's \ $x`}, nil},
+		{"no_upstream_remote", []string{"--config=NONE", "--output=env", "--shell=powershell"}, `$GPS_BRANCH = 'main'
+$GPS_TAG = ''
+$GPS_SHA = '24afc95'
+$GPS_STATE = ''
+$GPS_STEP = ''
+$GPS_TOTAL = ''
+$GPS_AHEAD = '0'
+$GPS_BEHIND = '0'
+$GPS_DIRTY = '0'
+$GPS_UNTRACKED = '0'
+$GPS_CONFLICT = '0'
+$GPS_BARE = '0'
+$GPS_SHALLOW = '0'
+$GPS_SPARSE = '0'
+$GPS_GIT_DIR = '$TMPDIR/testdata/no_upstream_remote/.git'
+$GPS_UPSTREAM_REMOTE = 'mikesmithgh/test'
+$GPS_UPSTREAM_BRANCH = 'it''s \ $x'
+$GPS_STAGED_COUNT = '0'
+$GPS_MODIFIED_COUNT = '0'
+$GPS_DELETED_COUNT = '0'
+$GPS_RENAMED_COUNT = '0'
+$GPS_UNTRACKED_COUNT = '0'
+$GPS_CONFLICTED_COUNT = '0'
+$GPS_STASH_COUNT = '0'
+$GPS_TIMED_OUT = '0'
+`, []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=branch.main.merge", `GIT_CONFIG_VALUE_0=refs/heads/it's \ $x`}, nil},
+		{"clean", []string{"--config=NONE", "--output=env", "--shell=tcsh"}, " git-prompt-string error(env): \"shell tcsh not supported by output env\\, expected one of bash\\, readline\\, raw\\, zsh\\, fish\\, powershell\"", nil, errors.New("exit status 1")},
+
+		// powerline
+		{"conflict_diverged", []string{"--config=NONE", "--powerline"}, "\x1b[0m\x1b[30m\x1b[43m \ue0a0 main \ue0b1\x1b[0m\x1b[30m\x1b[43m ↕ ↑[1] ↓[1] \x1b[0m\x1b[33m\ue0b0\x1b[0m", nil, nil},
+		{"rebase_i", []string{"--config=NONE", "--powerline", "--shell=zsh", "--powerline-separator=>", "--color-merge-state=bold white bg:#b30559"}, "%{\x1b[0m%}%{\x1b[30m\x1b[44m%} \ue0a0 main %{\x1b[0m\x1b[34m\x1b[48;2;179;5;89m%}>%{\x1b[0m%}%{\x1b[1m\x1b[37m\x1b[48;2;179;5;89m%} REBASE-i 1/1 %{\x1b[0m\x1b[38;2;179;5;89m%}>%{\x1b[0m%}", nil, nil},
+		{"dirty", []string{"--config=NONE", "--powerline", "--output=tmux", "--color-dirty-marker=black bg:yellow"}, "#[default]#[fg=black,bg=red] \ue0a0 main #[default,fg=red,bg=yellow]\ue0b0#[default]#[fg=black,bg=yellow] * #[default,fg=yellow]\ue0b0#[default]", nil, nil},
+		{"clean", []string{"--config=NONE", "--powerline", "--color-disabled", "--prompt-suffix= $"}, " \ue0a0 main \ue0b0 $", nil, nil},
+		{"clean", []string{"--config=NONE", "--powerline", "--color-branch=bg:nope"}, "\x1b[31m git-prompt-string error(powerline): \"color bg:nope not found\"\x1b[0m", nil, errors.New("exit status 1")},
+
+		// branch truncation
+		{"clean", []string{"--config=NONE", "--branch-max-length=3"}, "\x1b[32m \ue0a0 ma…\x1b[0m", nil, nil},
+		{"clean", []string{"--config=NONE", "--branch-max-length=3", "--branch-truncation=start", "--branch-ellipsis=.."}, "\x1b[32m \ue0a0 ..n\x1b[0m", nil, nil},
+		{"clean", []string{"--config=NONE", "--branch-max-length=4"}, "\x1b[32m \ue0a0 main\x1b[0m", nil, nil},
+		{"no_upstream_remote", []string{"--config=NONE", "--branch-max-length=17", "--branch-abbreviate"}, "\x1b[90m \ue0a0 main → mikesmithgh/test/f/PROJ-12345-ref…\x1b[0m", []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=branch.main.merge", "GIT_CONFIG_VALUE_0=refs/heads/feature/PROJ-12345-refactor-the-authentication-middleware"}, nil},
+		{"no_upstream_remote", []string{"--config=NONE", "--branch-max-length=20", "--branch-truncation=middle"}, "\x1b[90m \ue0a0 main → mikesmithgh/test/feature/PR…iddleware\x1b[0m", []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=branch.main.merge", "GIT_CONFIG_VALUE_0=refs/heads/feature/PROJ-12345-refactor-the-authentication-middleware"}, nil},
+		{"no_upstream_remote", []string{"--config=NONE", "--branch-abbreviate"}, "\x1b[90m \ue0a0 main → mikesmithgh/test/f/PROJ-12345-refactor-the-authentication-middleware\x1b[0m", []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=branch.main.merge", "GIT_CONFIG_VALUE_0=refs/heads/feature/PROJ-12345-refactor-the-authentication-middleware"}, nil},
+		{"no_upstream_remote", []string{"--config=NONE", "--output=tmux", "--branch-max-length=6"}, "#[fg=brightblack] \ue0a0 main → mikesmithgh/test/fix##1…#[default]", []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=branch.main.merge", "GIT_CONFIG_VALUE_0=refs/heads/fix#1234"}, nil},
+		{"clean", []string{"--config=NONE", "--branch-max-length=3", "--branch-truncation=left"}, "\x1b[31m git-prompt-string error(branch info): \"truncation position left not supported\\, expected one of start\\, middle\\, end\"\x1b[0m", nil, errors.New("exit status 1")},
+		{"clean", []string{"--config=NONE"}, "\x1b[32m \ue0a0 m…\x1b[0m", []string{"GIT_PROMPT_STRING_BRANCH_MAX_LENGTH=2"}, nil},
+
 		// color overrides
 		{"clean", []string{"--config=../configs/color_overrides.toml"}, "\x1b[38;2;230;238;4m \ue0a0 main\x1b[0m", nil, nil},
 		{"no_upstream", []string{"--config=../configs/color_overrides.toml"}, "\x1b[0m\x1b[30m\x1b[47m \ue0a0 main\x1b[0m", nil, nil},
@@ -72,12 +244,82 @@ func TestGitPromptString(t *testing.T) {
 		{"untracked", []string{"--config=../configs/color_overrides.toml"}, "\x1b[38;2;255;0;0m\x1b[48;2;22;242;170m \ue0a0 main *\x1b[0m", nil, nil},
 		{"bisect", []string{}, "\x1b[48;2;204;204;255m\x1b[35m \ue0a0 main|BISECTING ↓[1]\x1b[0m", []string{"GIT_PROMPT_STRING_CONFIG=../configs/color_overrides.toml"}, nil},
 
+		// color depth
+		{"clean", []string{"--config=../configs/color_overrides.toml", "--color-depth=256"}, "\x1b[38;5;190m \ue0a0 main\x1b[0m", nil, nil},
+		{"untracked", []string{"--config=../configs/color_overrides.toml", "--color-depth=16"}, "\x1b[91m\x1b[46m \ue0a0 main *\x1b[0m", nil, nil},
+		{"clean", []string{"--config=../configs/color_overrides.toml", "--color-depth=none"}, " \ue0a0 main", nil, nil},
+		{"clean", []string{"--config=../configs/color_overrides.toml"}, "\x1b[38;5;190m \ue0a0 main\x1b[0m", []string{"COLORTERM=", "TERM=xterm-256color"}, nil},
+		{"clean", []string{"--config=../configs/color_overrides.toml"}, "\x1b[93m \ue0a0 main\x1b[0m", []string{"COLORTERM=", "TERM=xterm"}, nil},
+		{"clean", []string{"--config=../configs/color_overrides.toml"}, " \ue0a0 main", []string{"COLORTERM=", "TERM=dumb"}, nil},
+		{"clean", []string{"--config=../configs/color_overrides.toml", "--color-depth=truecolor"}, "\x1b[38;2;230;238;4m \ue0a0 main\x1b[0m", []string{"COLORTERM=", "TERM=dumb"}, nil},
+		{"clean", []string{"--config=NONE", "--color-depth=8"}, "\x1b[31m git-prompt-string error(color depth): \"color depth 8 not supported\"\x1b[0m", nil, errors.New("exit status 1")},
+
+		// color policy
+		{"clean", []string{"--config=NONE"}, " \ue0a0 main", []string{"NO_COLOR=1"}, nil},
+		{"clean", []string{"--config=NONE"}, "\x1b[32m \ue0a0 main\x1b[0m", []string{"NO_COLOR="}, nil},
+		{"clean", []string{"--config=NONE"}, " \ue0a0 main", []string{"CLICOLOR=0"}, nil},
+		{"clean", []string{"--config=NONE"}, "\x1b[32m \ue0a0 main\x1b[0m", []string{"CLICOLOR=0", "CLICOLOR_FORCE=1"}, nil},
+		{"clean", []string{"--config=NONE"}, " \ue0a0 main", []string{"NO_COLOR=1", "CLICOLOR_FORCE=1"}, nil},
+		{"clean", []string{"--config=NONE", "--color=always"}, "\x1b[32m \ue0a0 main\x1b[0m", []string{"NO_COLOR=1"}, nil},
+		{"clean", []string{"--config=NONE", "--color=never"}, " \ue0a0 main", nil, nil},
+		{"clean", []string{"--config=NONE", "--color=always", "--color-disabled"}, " \ue0a0 main", nil, nil},
+		{"clean", []string{"--config=NONE", "--color=always"}, "\x1b[32m \ue0a0 main\x1b[0m", []string{"GIT_PROMPT_STRING_COLOR_DISABLED=true"}, nil},
+		{"clean", []string{"--config=NONE", "--color=auto"}, "\x1b[32m \ue0a0 main\x1b[0m", []string{"GIT_PROMPT_STRING_COLOR_DISABLED=true"}, nil},
+		{"clean", []string{"--config=NONE", "--color=auto"}, " \ue0a0 main", []string{"GIT_PROMPT_STRING_COLOR_DISABLED=true", "NO_COLOR=1"}, nil},
+		{"clean", []string{"--config=NONE"}, " \ue0a0 main", []string{"GIT_PROMPT_STRING_COLOR_DISABLED=true", "CLICOLOR_FORCE=1"}, nil},
+		{"clean", []string{"--config=../configs/color_always.toml"}, "\x1b[32m \ue0a0 main\x1b[0m", []string{"NO_COLOR=1"}, nil},
+		{"clean", []string{"--config=../configs/color_always.toml", "--color=auto"}, " \ue0a0 main", []string{"NO_COLOR=1"}, nil},
+		{"clean", []string{"--config=NONE", "--color=sometimes"}, "\x1b[31m git-prompt-string error(color): \"color sometimes not supported\\, expected one of auto\\, always\\, or never\"\x1b[0m", nil, errors.New("exit status 1")},
+
+		// repo config
+		{"repo_config", []string{}, "\x1b[36mrepo main from repo toml\x1b[0m", []string{"XDG_CONFIG_HOME=/xdg/does/not/exist"}, nil},
+		{"repo_config", []string{"--prompt-suffix= from flag", "--color-clean=blue"}, "\x1b[34mrepo main from flag\x1b[0m", []string{"XDG_CONFIG_HOME=/xdg/does/not/exist"}, nil},
+		{"repo_config", []string{"--config=../configs/color_overrides.toml"}, "\x1b[36mrepo main from repo toml\x1b[0m", nil, nil},
+		{"repo_config", []string{"--config=NONE"}, "\x1b[32m \ue0a0 main\x1b[0m", nil, nil},
+		{"repo_config", []string{}, "repo main from repo toml", []string{"XDG_CONFIG_HOME=/xdg/does/not/exist", "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=prompt-string.color-disabled", "GIT_CONFIG_VALUE_0="}, nil},
+		{"clean", []string{"--config=../configs/color_overrides.toml"}, "\x1b[38;2;230;238;4m \ue0a0 main stash\x1b[0m", []string{"GIT_CONFIG_COUNT=2", "GIT_CONFIG_KEY_0=prompt-string.prompt-suffix", "GIT_CONFIG_VALUE_0= stash", "GIT_CONFIG_KEY_1=prompt-string.count-changes", "GIT_CONFIG_VALUE_1=yes"}, nil},
+		{"clean", []string{"--config=../configs/color_overrides.toml"}, "\x1b[38;2;230;238;4m \ue0a0 main\x1b[0m", []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=prompt-string.colour-clean", "GIT_CONFIG_VALUE_0=red"}, nil},
+		{"clean", []string{"--config=../configs/color_overrides.toml"}, "\x1b[31m git-prompt-string error(repo config): \"git config prompt-string.count-changes: option count_changes: strconv.ParseBool: parsing \\\"maybe\\\": invalid syntax\"\x1b[0m", []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=prompt-string.count-changes", "GIT_CONFIG_VALUE_0=maybe"}, errors.New("exit status 1")},
+
+		// environment variables
+		{"clean", []string{"--config=NONE"}, "\x1b[36m env main\x1b[0m", []string{"GIT_PROMPT_STRING_COLOR_CLEAN=cyan", "GIT_PROMPT_STRING_PROMPT_PREFIX= env "}, nil},
+		{"clean", []string{"--config=NONE", "--color-clean=blue"}, "\x1b[34m \ue0a0 main\x1b[0m", []string{"GIT_PROMPT_STRING_COLOR_CLEAN=cyan"}, nil},
+		{"clean", []string{"--config=../configs/color_overrides.toml"}, " \ue0a0 main", []string{"GIT_PROMPT_STRING_COLOR_DISABLED=true"}, nil},
+		{"repo_config", []string{}, "\x1b[36mrepo main from env\x1b[0m", []string{"XDG_CONFIG_HOME=/xdg/does/not/exist", "GIT_PROMPT_STRING_PROMPT_SUFFIX= from env"}, nil},
+		{"clean", []string{"--config=NONE"}, "\x1b[31m git-prompt-string error(env): \"environment variable GIT_PROMPT_STRING_COUNT_CHANGES: option count_changes: strconv.ParseBool: parsing \\\"maybe\\\": invalid syntax\"\x1b[0m", []string{"GIT_PROMPT_STRING_COUNT_CHANGES=maybe"}, errors.New("exit status 1")},
+
+		// match
+		{"clean", []string{"--config=../configs/match.toml"}, "\x1b[31m mikesmithgh main in clean\x1b[0m", nil, nil},
+		{"dirty", []string{"--config=../configs/match.toml"}, "\x1b[31m mikesmithgh main * on main\x1b[0m", nil, nil},
+		{"tag", []string{"--config=../configs/match.toml", "--color-no-upstream=blue"}, "\x1b[34m mikesmithgh (v1.0.0)\x1b[0m", nil, nil},
+		{"clean", []string{"--config=../configs/match.toml", "--prompt-suffix= flag"}, "\x1b[31m mikesmithgh main flag\x1b[0m", nil, nil},
+		{"clean", []string{"--config=../configs/match_invalid.toml"}, "\x1b[31m git-prompt-string error(unmarshal config): \"match 1: branch_regex: error parsing regexp: missing closing \\): \\`\\(\\`\"\x1b[0m", nil, errors.New("exit status 1")},
+
 		// config errors
 		{"clean", []string{"--config=/fromparam/does/not/exist"}, fmt.Sprintf("\x1b[31m git-prompt-string error(read config): \"open /fromparam/does/not/exist: %s\"\x1b[0m", notFoundMsg), nil, errors.New("exit status 1")},
 		{"configs", []string{}, fmt.Sprintf("\x1b[31m git-prompt-string error(read config): \"open /fromenvvar/does/not/exist: %s\"\x1b[0m", notFoundMsg), []string{"GIT_PROMPT_STRING_CONFIG=/fromenvvar/does/not/exist"}, errors.New("exit status 1")},
 		{"configs", []string{"--config=invalid_syntax.toml"}, fmt.Sprintf("\x1b[31m git-prompt-string error(unmarshal config): \"toml: expected character %s\"\x1b[0m", escapedEqualSign), nil, errors.New("exit status 1")},
 		{"configs", []string{}, fmt.Sprintf("\x1b[31m git-prompt-string error(unmarshal config): \"toml: expected character %s\"\x1b[0m", escapedEqualSign), []string{"GIT_PROMPT_STRING_CONFIG=invalid_syntax.toml"}, errors.New("exit status 1")},
 
+		// config check
+		{"configs", []string{"config", "check", "match.toml"}, "match.toml: ok\n", nil, nil},
+		{"configs", []string{"config", "check"}, "match.toml: ok\n", []string{"GIT_PROMPT_STRING_CONFIG=match.toml"}, nil},
+		{"clean", []string{"config", "check"}, "../configs/match.toml: ok\ngit config prompt-string.colour-clean: unknown option colour_clean\n", []string{"GIT_PROMPT_STRING_CONFIG=../configs/match.toml", "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=prompt-string.colour-clean", "GIT_CONFIG_VALUE_0=red"}, errors.New("exit status 1")},
+		{"clean", []string{"config", "check", "../configs/match.toml"}, "../configs/match.toml: ok\n", []string{"GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=prompt-string.colour-clean", "GIT_CONFIG_VALUE_0=red"}, nil},
+		{"clean", []string{"config", "check"}, "../configs/match.toml: ok\n", []string{"GIT_PROMPT_STRING_CONFIG=../configs/match.toml"}, nil},
+		{"configs", []string{"config", "check", "invalid_syntax.toml"}, "invalid_syntax.toml:1:9: expected character =\n", nil, errors.New("exit status 1")},
+		{"configs", []string{"config", "check", "check_invalid.toml"}, strings.Join([]string{
+			"check_invalid.toml:2:1: unknown option colour_clean",
+			"check_invalid.toml:3:1: color_dirty: color reddish not found",
+			"check_invalid.toml:4:1: ahead_format: expected 1 %v verb, got 2",
+			"check_invalid.toml:5:1: diverged_format: expected 2 %v verbs, got 1",
+			"check_invalid.toml:11:1: match 1: color_branch: hex must be 3 or 6 digits, got 12",
+			"check_invalid.toml:12:1: match 1: behind_format: expected 1 %v verb, got 0",
+		}, "\n") + "\n", nil, errors.New("exit status 1")},
+		{"configs", []string{"config", "check", "match_invalid.toml"}, "match_invalid.toml:2:1: match 1: branch_regex: error parsing regexp: missing closing ): `(`\n", nil, errors.New("exit status 1")},
+		{"configs", []string{"--config=NONE", "config", "check"}, "\x1b[31m git-prompt-string error(config check): \"no configuration file to check\"\x1b[0m", nil, errors.New("exit status 1")},
+		{"configs", []string{"config", "lint"}, "\x1b[31m git-prompt-string error(config): \"unknown subcommand lint\\, expected one of check\\, print\\, or init\"\x1b[0m", nil, errors.New("exit status 1")},
+
 		{"norepo", []string{"--config=NONE"}, "", nil, nil},
 
 		// json
@@ -90,7 +332,34 @@ func TestGitPromptString(t *testing.T) {
   "branchStatus": "",
   "color": "bright-black",
   "promptPrefix": "  ",
-  "promptSuffix": ""
+  "promptSuffix": "",
+  "version": 1,
+  "branch": "main",
+  "tag": "",
+  "shortSha": "",
+  "gitDir": "$TMPDIR/testdata/bare",
+  "insideGitDir": true,
+  "bare": true,
+  "shallow": false,
+  "sparse": false,
+  "mergeState": "",
+  "step": 0,
+  "total": 0,
+  "conflict": false,
+  "ahead": 0,
+  "behind": 0,
+  "clean": true,
+  "untracked": false,
+  "upstreamRemote": "",
+  "upstreamBranch": "",
+  "stagedCount": 0,
+  "modifiedCount": 0,
+  "deletedCount": 0,
+  "renamedCount": 0,
+  "untrackedCount": 0,
+  "conflictedCount": 0,
+  "stashCount": 0,
+  "timedOut": false
 }
 `), nil, nil,
 		},
@@ -100,7 +369,70 @@ func TestGitPromptString(t *testing.T) {
   "branchStatus": "",
   "color": "bright-black",
   "promptPrefix": "  ",
-  "promptSuffix": ""
+  "promptSuffix": "",
+  "version": 1,
+  "branch": "main",
+  "tag": "",
+  "shortSha": "24afc95",
+  "gitDir": "$TMPDIR/testdata/no_upstream_remote/.git",
+  "insideGitDir": false,
+  "bare": false,
+  "shallow": false,
+  "sparse": false,
+  "mergeState": "",
+  "step": 0,
+  "total": 0,
+  "conflict": false,
+  "ahead": 0,
+  "behind": 0,
+  "clean": true,
+  "untracked": false,
+  "upstreamRemote": "mikesmithgh/test",
+  "upstreamBranch": "main",
+  "stagedCount": 0,
+  "modifiedCount": 0,
+  "deletedCount": 0,
+  "renamedCount": 0,
+  "untrackedCount": 0,
+  "conflictedCount": 0,
+  "stashCount": 0,
+  "timedOut": false
+}
+    `), nil, nil},
+		{"percent_branch", []string{"--config=NONE", "--json", "--shell=zsh"}, strings.TrimSpace(`
+{
+  "branchInfo": "fe%at",
+  "branchStatus": "",
+  "color": "green",
+  "promptPrefix": "  ",
+  "promptSuffix": "",
+  "version": 1,
+  "branch": "fe%at",
+  "tag": "",
+  "shortSha": "24afc95",
+  "gitDir": "$TMPDIR/testdata/percent_branch/.git",
+  "insideGitDir": false,
+  "bare": false,
+  "shallow": false,
+  "sparse": false,
+  "mergeState": "",
+  "step": 0,
+  "total": 0,
+  "conflict": false,
+  "ahead": 0,
+  "behind": 0,
+  "clean": true,
+  "untracked": false,
+  "upstreamRemote": "origin",
+  "upstreamBranch": "fe%at",
+  "stagedCount": 0,
+  "modifiedCount": 0,
+  "deletedCount": 0,
+  "renamedCount": 0,
+  "untrackedCount": 0,
+  "conflictedCount": 0,
+  "stashCount": 0,
+  "timedOut": false
 }
     `), nil, nil},
 		{"git_dir", []string{"--config=NONE", "--json"}, strings.TrimSpace(`
@@ -109,7 +441,34 @@ func TestGitPromptString(t *testing.T) {
   "branchStatus": "",
   "color": "bright-black",
   "promptPrefix": "  ",
-  "promptSuffix": ""
+  "promptSuffix": "",
+  "version": 1,
+  "branch": "main",
+  "tag": "",
+  "shortSha": "",
+  "gitDir": "$TMPDIR/testdata/git_dir",
+  "insideGitDir": true,
+  "bare": false,
+  "shallow": false,
+  "sparse": false,
+  "mergeState": "",
+  "step": 0,
+  "total": 0,
+  "conflict": false,
+  "ahead": 0,
+  "behind": 0,
+  "clean": true,
+  "untracked": false,
+  "upstreamRemote": "",
+  "upstreamBranch": "",
+  "stagedCount": 0,
+  "modifiedCount": 0,
+  "deletedCount": 0,
+  "renamedCount": 0,
+  "untrackedCount": 0,
+  "conflictedCount": 0,
+  "stashCount": 0,
+  "timedOut": false
 }
     `), nil, nil},
 		{"clean", []string{"--config=NONE", "--json", "--prompt-prefix=a"}, strings.TrimSpace(`
@@ -118,7 +477,34 @@ func TestGitPromptString(t *testing.T) {
   "branchStatus": "",
   "color": "green",
   "promptPrefix": "a",
-  "promptSuffix": ""
+  "promptSuffix": "",
+  "version": 1,
+  "branch": "main",
+  "tag": "",
+  "shortSha": "24afc95",
+  "gitDir": "$TMPDIR/testdata/clean/.git",
+  "insideGitDir": false,
+  "bare": false,
+  "shallow": false,
+  "sparse": false,
+  "mergeState": "",
+  "step": 0,
+  "total": 0,
+  "conflict": false,
+  "ahead": 0,
+  "behind": 0,
+  "clean": true,
+  "untracked": false,
+  "upstreamRemote": "origin",
+  "upstreamBranch": "main",
+  "stagedCount": 0,
+  "modifiedCount": 0,
+  "deletedCount": 0,
+  "renamedCount": 0,
+  "untrackedCount": 0,
+  "conflictedCount": 0,
+  "stashCount": 0,
+  "timedOut": false
 }
     `), nil, nil},
 		{"tag", []string{"--config=NONE", "--json", "--prompt-suffix=z"}, strings.TrimSpace(`
@@ -127,7 +513,34 @@ func TestGitPromptString(t *testing.T) {
   "branchStatus": "",
   "color": "bright-black",
   "promptPrefix": "  ",
-  "promptSuffix": "z"
+  "promptSuffix": "z",
+  "version": 1,
+  "branch": "",
+  "tag": "v1.0.0",
+  "shortSha": "24afc95",
+  "gitDir": "$TMPDIR/testdata/tag/.git",
+  "insideGitDir": false,
+  "bare": false,
+  "shallow": false,
+  "sparse": false,
+  "mergeState": "",
+  "step": 0,
+  "total": 0,
+  "conflict": false,
+  "ahead": 0,
+  "behind": 0,
+  "clean": true,
+  "untracked": false,
+  "upstreamRemote": "",
+  "upstreamBranch": "",
+  "stagedCount": 0,
+  "modifiedCount": 0,
+  "deletedCount": 0,
+  "renamedCount": 0,
+  "untrackedCount": 0,
+  "conflictedCount": 0,
+  "stashCount": 0,
+  "timedOut": false
 }
     `), nil, nil},
 		{"dirty", []string{"--config=NONE", "--json", "--color-dirty=CustomRed"}, strings.TrimSpace(`
@@ -136,7 +549,34 @@ func TestGitPromptString(t *testing.T) {
   "branchStatus": " *",
   "color": "CustomRed",
   "promptPrefix": "  ",
-  "promptSuffix": ""
+  "promptSuffix": "",
+  "version": 1,
+  "branch": "main",
+  "tag": "",
+  "shortSha": "24afc95",
+  "gitDir": "$TMPDIR/testdata/dirty/.git",
+  "insideGitDir": false,
+  "bare": false,
+  "shallow": false,
+  "sparse": false,
+  "mergeState": "",
+  "step": 0,
+  "total": 0,
+  "conflict": false,
+  "ahead": 0,
+  "behind": 0,
+  "clean": false,
+  "untracked": false,
+  "upstreamRemote": "origin",
+  "upstreamBranch": "main",
+  "stagedCount": 0,
+  "modifiedCount": 1,
+  "deletedCount": 0,
+  "renamedCount": 0,
+  "untrackedCount": 0,
+  "conflictedCount": 0,
+  "stashCount": 0,
+  "timedOut": false
 }
     `), nil, nil},
 		{"conflict_diverged", []string{"--config=NONE", "--json"}, strings.TrimSpace(`
@@ -145,7 +585,34 @@ func TestGitPromptString(t *testing.T) {
   "branchStatus": " ↕ ↑[1] ↓[1]",
   "color": "yellow",
   "promptPrefix": "  ",
-  "promptSuffix": ""
+  "promptSuffix": "",
+  "version": 1,
+  "branch": "main",
+  "tag": "",
+  "shortSha": "ac2180e",
+  "gitDir": "$TMPDIR/testdata/conflict_diverged/.git",
+  "insideGitDir": false,
+  "bare": false,
+  "shallow": false,
+  "sparse": false,
+  "mergeState": "",
+  "step": 0,
+  "total": 0,
+  "conflict": false,
+  "ahead": 1,
+  "behind": 1,
+  "clean": true,
+  "untracked": false,
+  "upstreamRemote": "origin",
+  "upstreamBranch": "main",
+  "stagedCount": 0,
+  "modifiedCount": 0,
+  "deletedCount": 0,
+  "renamedCount": 0,
+  "untrackedCount": 0,
+  "conflictedCount": 0,
+  "stashCount": 0,
+  "timedOut": false
 }
     `), nil, nil},
 		{"untracked", []string{"--config=NONE", "--json"}, strings.TrimSpace(`
@@ -154,7 +621,70 @@ func TestGitPromptString(t *testing.T) {
   "branchStatus": " *",
   "color": "magenta",
   "promptPrefix": "  ",
-  "promptSuffix": ""
+  "promptSuffix": "",
+  "version": 1,
+  "branch": "main",
+  "tag": "",
+  "shortSha": "24afc95",
+  "gitDir": "$TMPDIR/testdata/untracked/.git",
+  "insideGitDir": false,
+  "bare": false,
+  "shallow": false,
+  "sparse": false,
+  "mergeState": "",
+  "step": 0,
+  "total": 0,
+  "conflict": false,
+  "ahead": 0,
+  "behind": 0,
+  "clean": true,
+  "untracked": true,
+  "upstreamRemote": "origin",
+  "upstreamBranch": "main",
+  "stagedCount": 0,
+  "modifiedCount": 0,
+  "deletedCount": 0,
+  "renamedCount": 0,
+  "untrackedCount": 1,
+  "conflictedCount": 0,
+  "stashCount": 0,
+  "timedOut": false
+}
+    `), nil, nil},
+		{"stash", []string{"--config=NONE", "--json"}, strings.TrimSpace(`
+{
+  "branchInfo": "main",
+  "branchStatus": " ≡2",
+  "color": "green",
+  "promptPrefix": "  ",
+  "promptSuffix": "",
+  "version": 1,
+  "branch": "main",
+  "tag": "",
+  "shortSha": "24afc95",
+  "gitDir": "$TMPDIR/testdata/stash/.git",
+  "insideGitDir": false,
+  "bare": false,
+  "shallow": false,
+  "sparse": false,
+  "mergeState": "",
+  "step": 0,
+  "total": 0,
+  "conflict": false,
+  "ahead": 0,
+  "behind": 0,
+  "clean": true,
+  "untracked": false,
+  "upstreamRemote": "origin",
+  "upstreamBranch": "main",
+  "stagedCount": 0,
+  "modifiedCount": 0,
+  "deletedCount": 0,
+  "renamedCount": 0,
+  "untrackedCount": 0,
+  "conflictedCount": 0,
+  "stashCount": 2,
+  "timedOut": false
 }
     `), nil, nil},
 		{"sparse", []string{"--config=NONE", "--json"}, strings.TrimSpace(`
@@ -163,11 +693,78 @@ func TestGitPromptString(t *testing.T) {
   "branchStatus": "",
   "color": "green",
   "promptPrefix": "  ",
-  "promptSuffix": ""
+  "promptSuffix": "",
+  "version": 1,
+  "branch": "main",
+  "tag": "",
+  "shortSha": "24afc95",
+  "gitDir": "$TMPDIR/testdata/sparse/.git",
+  "insideGitDir": false,
+  "bare": false,
+  "shallow": false,
+  "sparse": true,
+  "mergeState": "",
+  "step": 0,
+  "total": 0,
+  "conflict": false,
+  "ahead": 0,
+  "behind": 0,
+  "clean": true,
+  "untracked": false,
+  "upstreamRemote": "origin",
+  "upstreamBranch": "main",
+  "stagedCount": 0,
+  "modifiedCount": 0,
+  "deletedCount": 0,
+  "renamedCount": 0,
+  "untrackedCount": 0,
+  "conflictedCount": 0,
+  "stashCount": 0,
+  "timedOut": false
 }     
+    `), nil, nil},
+		{"rebase_i", []string{"--config=NONE", "--json", "--color-disabled"}, strings.TrimSpace(`
+{
+  "branchInfo": "main|REBASE-i 1/1",
+  "branchStatus": "",
+  "color": "",
+  "promptPrefix": "  ",
+  "promptSuffix": "",
+  "version": 1,
+  "branch": "main",
+  "tag": "",
+  "shortSha": "b69e688",
+  "gitDir": "$TMPDIR/testdata/rebase_i/.git",
+  "insideGitDir": false,
+  "bare": false,
+  "shallow": false,
+  "sparse": false,
+  "mergeState": "REBASE-i",
+  "step": 1,
+  "total": 1,
+  "conflict": false,
+  "ahead": 0,
+  "behind": 0,
+  "clean": true,
+  "untracked": false,
+  "upstreamRemote": "",
+  "upstreamBranch": "",
+  "stagedCount": 0,
+  "modifiedCount": 0,
+  "deletedCount": 0,
+  "renamedCount": 0,
+  "untrackedCount": 0,
+  "conflictedCount": 0,
+  "stashCount": 0,
+  "timedOut": false
+}
     `), nil, nil},
 	}
 
+	realTmpDir, err := filepath.EvalSymlinks(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
 	for _, test := range tests {
 		cmd := exec.Command(builtBinaryPath, test.input...)
 		cmd.Dir = filepath.Join(tmpDir, "testdata", test.dir)
@@ -181,7 +778,8 @@ func TestGitPromptString(t *testing.T) {
 		} else if test.err != nil && test.err.Error() != err.Error() {
 			t.Errorf("Expected error: %s, got: %s", test.err, err)
 		}
-		actual := string(result)
+		// the git directory in the JSON output is an absolute path
+		actual := strings.ReplaceAll(string(result), realTmpDir, "$TMPDIR")
 		if actual != test.expected {
 			t.Errorf("in directory %s, %s != %s\nexpected:\n%q, \ngot:\n%q", test.dir, test.expected, actual, test.expected, actual)
 		}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -56,6 +57,30 @@ func TestMain(m *testing.M) {
 			}
 		}
 	}
+	// expect truecolor regardless of the terminal running the tests
+	err = os.Setenv("COLORTERM", "truecolor")
+	if err != nil {
+		panic(fmt.Sprintf("failed to set COLORTERM: %s", err))
+	}
+	// a daemon of the user running the tests would answer instead of the built binary
+	err = os.Setenv("XDG_RUNTIME_DIR", filepath.Join(tmpDir, "runtime"))
+	if err != nil {
+		panic(fmt.Sprintf("failed to set XDG_RUNTIME_DIR: %s", err))
+	}
+	keys := []string{"NO_COLOR", "CLICOLOR", "CLICOLOR_FORCE"}
+	for _, kv := range os.Environ() {
+		// options set in the environment of the tests would override the expected defaults
+		if key, _, _ := strings.Cut(kv, "="); strings.HasPrefix(key, "GIT_PROMPT_STRING_") {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		err = os.Unsetenv(key)
+		if err != nil {
+			panic(fmt.Sprintf("failed to unset %s: %s", key, err))
+		}
+	}
+
 	fmt.Println("=== INIT")
 	fmt.Println("tmpDir:", tmpDir)
 	fmt.Println("builtBinaryPath:", builtBinaryPath)
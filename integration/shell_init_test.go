@@ -0,0 +1,62 @@
+package integration
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		golden string
+		input  []string
+	}{
+		{"bash", []string{"init", "bash"}},
+		{"zsh", []string{"init", "zsh"}},
+		{"fish", []string{"init", "fish"}},
+		{"powershell", []string{"init", "powershell"}},
+		{"nushell", []string{"init", "nushell"}},
+		{"bash_flags", []string{"--config=NONE", "init", "bash", "--prompt-suffix= it's done", "--count-changes"}},
+		{"zsh_flags", []string{"init", "zsh", "--config=/path/with space/config.toml", "--color-disabled"}},
+		{"fish_flags", []string{"init", "fish", "--prompt-prefix='\\ "}},
+		{"powershell_flags", []string{"init", "powershell", "--prompt-suffix=it's", "--json"}},
+		{"nushell_flags", []string{"init", "nushell", "--format={{.Branch}} \"$(x)\""}},
+	}
+
+	for _, test := range tests {
+		cmd := exec.Command(builtBinaryPath, test.input...)
+		result, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Errorf("Unexpected error: %s: %s", err, result)
+		}
+		goldenPath := filepath.Join("testdata", "init", test.golden+".golden")
+		if *update {
+			if err := os.WriteFile(goldenPath, result, 0o644); err != nil { //nolint:gosec // golden files are not sensitive
+				t.Fatalf("failed to update golden file: %s", err)
+			}
+		}
+		expected, err := os.ReadFile(goldenPath)
+		if err != nil {
+			t.Fatalf("failed to read golden file: %s", err)
+		}
+		if string(result) != string(expected) {
+			t.Errorf("%s does not match %s\nexpected:\n%s\ngot:\n%s", test.input, goldenPath, expected, result)
+		}
+	}
+}
+
+func TestInitUnsupportedShell(t *testing.T) {
+	cmd := exec.Command(builtBinaryPath, "init", "cmd")
+	result, err := cmd.CombinedOutput()
+	if err == nil || err.Error() != "exit status 1" {
+		t.Errorf("Expected error: exit status 1, got: %v", err)
+	}
+	expected := "\x1b[31m git-prompt-string error(init): \"shell cmd not supported\\, expected one of bash\\, zsh\\, fish\\, powershell\\, nushell\"\x1b[0m"
+	if string(result) != expected {
+		t.Errorf("expected:\n%q, \ngot:\n%q", expected, result)
+	}
+}
@@ -0,0 +1,78 @@
+//go:build !windows
+
+package integration
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// slowGit is a git wrapper that never finishes the commands used to determine
+// the status of the working tree.
+const slowGit = `#!/bin/sh
+case "$1" in
+--no-optional-locks | diff | ls-files | rev-list) exec sleep 5 ;;
+esac
+exec %s "$@"
+`
+
+// hungGit is a git wrapper that never finishes any command, including git
+// rev-parse.
+const hungGit = `#!/bin/sh
+exec sleep 5
+`
+
+// writeGitWrapper writes the git wrapper script to a bin directory named name
+// and returns the directory.
+func writeGitWrapper(t testing.TB, name string, script string) string {
+	t.Helper()
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatalf("failed to find git: %s", err)
+	}
+	binDir := filepath.Join(tmpDir, name)
+	if err := os.MkdirAll(binDir, 0o755); err != nil {
+		t.Fatalf("failed to create bin dir: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(binDir, "git"), []byte(fmt.Sprintf(script, gitPath)), 0o755); err != nil { //nolint:gosec // the wrapper must be executable
+		t.Fatalf("failed to write git wrapper: %s", err)
+	}
+	return binDir
+}
+
+func TestTimeout(t *testing.T) {
+	slowBin := writeGitWrapper(t, "slowbin", slowGit)
+	hungBin := writeGitWrapper(t, "hungbin", hungGit)
+
+	tests := []struct {
+		dir      string
+		binDir   string
+		input    []string
+		expected string
+	}{
+		{"clean", slowBin, []string{"--config=NONE", "--timeout=1s"}, "\x1b[36m \ue0a0 main ?\x1b[0m"},
+		{"merge_conflict", slowBin, []string{"--config=NONE", "--timeout=1s", "--timeout-marker=…", "--color-timeout=bright-black"}, "\x1b[90m \ue0a0 main|MERGING …\x1b[0m"},
+		{"clean", slowBin, []string{"--config=NONE", "--timeout=1s", "--format={{.PromptBranch}} {{.TimedOut}}"}, "main true"},
+		// git rev-parse times out, so the repository is found without git
+		{"clean", hungBin, []string{"--config=NONE", "--timeout=300ms"}, "\x1b[36m \ue0a0 main ?\x1b[0m"},
+		{"rebase_i", hungBin, []string{"--config=NONE", "--timeout=300ms", "--color-disabled"}, " \ue0a0 main|REBASE-i 1/1 ?"},
+		{"norepo", hungBin, []string{"--config=NONE", "--timeout=300ms"}, ""},
+	}
+
+	for _, test := range tests {
+		cmd := exec.Command(builtBinaryPath, test.input...)
+		cmd.Dir = filepath.Join(tmpDir, "testdata", test.dir)
+		cmd.Env = append(os.Environ(), "PATH="+test.binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+		result, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+		actual := string(result)
+		if actual != test.expected {
+			t.Errorf("in directory %s, %s != %s\nexpected:\n%q, \ngot:\n%q", test.dir, test.expected, actual, test.expected, actual)
+		}
+	}
+}
@@ -1,20 +1,26 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path"
-	"strconv"
+	"regexp"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/mikesmithgh/git-prompt-string/pkg/color"
 	"github.com/mikesmithgh/git-prompt-string/pkg/config"
+	"github.com/mikesmithgh/git-prompt-string/pkg/daemon"
 	"github.com/mikesmithgh/git-prompt-string/pkg/git"
+	"github.com/mikesmithgh/git-prompt-string/pkg/prompt"
+	"github.com/mikesmithgh/git-prompt-string/pkg/shell"
 	"github.com/mikesmithgh/git-prompt-string/pkg/util"
-	"github.com/pelletier/go-toml/v2"
 )
 
 var (
@@ -29,14 +35,47 @@ var (
 	divergedFormat         = flag.String("diverged-format", "↕ ↑[%v] ↓[%v]", "The format used to indicate the number of commits diverged\nfrom the remote branch. The first %v verb represents the number\nof commits ahead of the remote branch. The second %v verb\nrepresents the number of commits behind the remote branch. Two\n%v verbs are required.")
 	noUpstreamRemoteFormat = flag.String("no-upstream-remote-format", " → %v/%v", "The format used to indicate when there is no remote upstream,\nbut there is still a remote branch configured. The first %v\nrepresents the remote repository. The second %v represents the\nremote branch. Two %v are required.")
 	colorDisabled          = flag.Bool("color-disabled", false, "Disable all colors in the prompt.")
+	colorPolicy            = flag.String("color", "auto", "When to display colors in the prompt. One of auto, always, or never.\nIf auto, colors are disabled when the NO_COLOR environment variable\nis set or CLICOLOR is 0, unless CLICOLOR_FORCE is set. --color takes\nprecedence over color_disabled, unless --color-disabled is also set.")
 	colorClean             = flag.String("color-clean", "green", "The color of the prompt when the working directory is clean.\n")
 	colorDelta             = flag.String("color-delta", "yellow", "The color of the prompt when the local branch is ahead, behind,\nor has diverged from the remote branch.")
 	colorDirty             = flag.String("color-dirty", "red", "The color of the prompt when the working directory has changes\nthat have not yet been committed.")
 	colorUntracked         = flag.String("color-untracked", "magenta", "The color of the prompt when there are untracked files in the\nworking directory.")
 	colorNoUpstream        = flag.String("color-no-upstream", "bright-black", "The color of the prompt when there is no remote upstream branch.\n")
 	colorMerging           = flag.String("color-merging", "blue", "The color of the prompt during a merge, rebase, cherry-pick,\nrevert, or bisect.")
-	jsonFormat             = flag.Bool("json", false, "Output the results in JSON format. The keys of the JSON result are\nbranchInfo, branchStatus, color, promptPrefix, and promptSuffix.\n\nExample:\n{\n  \"branchInfo\": \"main\",\n  \"branchStatus\": \"\",\n  \"color\": \"green\",\n  \"promptPrefix\": \"  \",\n  \"promptSuffix\": \"\"\n}")
+	colorPrefix            = flag.String("color-prefix", "", "The color of the prompt prefix. Defaults to the color of the prompt.")
+	colorBranch            = flag.String("color-branch", "", "The color of the branch. Defaults to the color of the prompt.")
+	colorMergeState        = flag.String("color-merge-state", "", "The color of the merge, rebase, cherry-pick, revert, or bisect\nstate. Defaults to the color of the prompt.")
+	colorSparse            = flag.String("color-sparse", "", "The color of the sparse checkout indicator. Defaults to the color\nof the prompt.")
+	colorAheadBehind       = flag.String("color-ahead-behind", "", "The color of the ahead, behind, or diverged commit counts.\nDefaults to the color of the prompt.")
+	colorDirtyMarker       = flag.String("color-dirty-marker", "", "The color of the marker indicating uncommitted changes or\nuntracked files. Defaults to the color of the prompt.")
+	colorSuffix            = flag.String("color-suffix", "", "The color of the prompt suffix. Defaults to the color of the prompt.")
+	format                 = flag.String("format", prompt.DefaultFormat, "A Go text/template that controls the layout of the prompt. See\nhttps://github.com/mikesmithgh/git-prompt-string#prompt-format for\nthe available fields and functions.")
+	countChanges           = flag.Bool("count-changes", false, "Display the number of changes in each category instead of *\nwhen the working directory has changes or untracked files.")
+	stagedFormat           = flag.String("staged-format", "+%v", "The format used to indicate the number of staged changes. The %v\nverb represents the number of changes. One %v verb is required.")
+	modifiedFormat         = flag.String("modified-format", "~%v", "The format used to indicate the number of modified files that\nare not staged. The %v verb represents the number of files. One\n%v verb is required.")
+	deletedFormat          = flag.String("deleted-format", "-%v", "The format used to indicate the number of deleted files that\nare not staged. The %v verb represents the number of files. One\n%v verb is required.")
+	renamedFormat          = flag.String("renamed-format", "»%v", "The format used to indicate the number of renamed files. The %v\nverb represents the number of files. One %v verb is required.")
+	untrackedFormat        = flag.String("untracked-format", "?%v", "The format used to indicate the number of untracked files. The\n%v verb represents the number of files. One %v verb is required.")
+	conflictedFormat       = flag.String("conflicted-format", "!%v", "The format used to indicate the number of files with conflicts.\nThe %v verb represents the number of files. One %v verb is\nrequired.")
+	stashFormat            = flag.String("stash-format", " ≡%v", "The format used to indicate the number of stash entries. The %v\nverb represents the number of entries. One %v verb is required.\nSet to an empty string to hide the stash count.")
+	colorStash             = flag.String("color-stash", "", "The color of the stash count. Defaults to the color of the prompt.")
+	timeout                = flag.String("timeout", "", "The maximum duration to wait for git, e.g., 200ms or 1s. If git does\nnot respond in time, the prompt is displayed with the timeout marker\nand timeout color. The default is to wait indefinitely.")
+	timeoutMarker          = flag.String("timeout-marker", "?", "The marker displayed in place of * when git did not respond\nbefore the timeout and the state of the working directory is unknown.")
+	colorTimeout           = flag.String("color-timeout", "cyan", "The color of the prompt when git did not respond before the timeout.\n")
+	shellFlag              = flag.String("shell", "raw", "The shell that displays the prompt. Escape sequences are wrapped in the\nnon-printing delimiters of the shell so that the width of the prompt is\ncalculated correctly. One of bash, readline, zsh, tcsh, fish, powershell,\nor raw. Use bash when the output is added to PS1 before it is displayed\nand readline when PS1 expands the output, e.g., $(git-prompt-string).")
+	colorDepth             = flag.String("color-depth", "auto", "The number of colors the terminal can display. One of truecolor, 256,\n16, none, or auto. Colors are mapped to the nearest color that can be\ndisplayed. If auto, the depth is detected from the COLORTERM and TERM\nenvironment variables.")
+	outputFlag             = flag.String("output", "prompt", "The format of the output. One of prompt, tmux, or env. If tmux, colors\nare rendered as the style markup of the tmux status line, e.g.,\n#[fg=green], and # in branch names is escaped as ##. If env, the state\nof the repository is printed as variable assignments of the shell, e.g.,\nGPS_BRANCH=main, to evaluate in scripts.")
+	powerline              = flag.Bool("powerline", false, "Render the prompt as powerline segments instead of the format. The\nbranch, merge state, ahead/behind, dirty, and stash segments are\ndisplayed in their segment colors, or black on the color of the prompt\nif not set, and are joined by the powerline separator.")
+	powerlineSeparator     = flag.String("powerline-separator", "\ue0b0", "The glyph between powerline segments. Its foreground is the background\nof the segment before it and its background is the background of the\nsegment after it, e.g., \ue0b0 (nf-pl-left_hard_divider), \ue0b4\n(nf-ple-right_half_circle_thick), or >.\n\\ue0b0 is the unicode representation of \ue0b0.")
+	powerlineThinSeparator = flag.String("powerline-thin-separator", "\ue0b1", "The glyph between powerline segments with the same background, which\nare not told apart by the powerline separator. It is displayed in the\ncolors of the segment before it.\n\\ue0b1 is the unicode representation of \ue0b1.")
+	branchMaxLength        = flag.Int("branch-max-length", 0, "The maximum width of the branch and the upstream branch in columns.\nLonger names are shortened, first by the branch abbreviation if enabled,\nthen by the branch truncation. If 0, names are not shortened.")
+	branchTruncation       = flag.String("branch-truncation", "end", "The position where characters of a branch longer than the branch max\nlength are replaced by the branch ellipsis. One of start, middle, or end.")
+	branchEllipsis         = flag.String("branch-ellipsis", "…", "The marker that replaces the characters removed from a branch longer\nthan the branch max length.")
+	branchAbbreviate       = flag.Bool("branch-abbreviate", false, "Shorten each component of a branch but the last to its first character,\ne.g., f/PROJ-123 for feature/PROJ-123, if the branch is longer than the\nbranch max length or the branch max length is 0.")
+	jsonFormat             = flag.Bool("json", false, "Output the results as a versioned JSON document. See\nhttps://github.com/mikesmithgh/git-prompt-string#json-output for the\nkeys of the document.")
 	versionFlag            = flag.Bool("version", false, "Print version information for git-prompt-string.")
+	daemonIdleTimeout      = flag.Duration("daemon-idle-timeout", 30*time.Minute, "How long the daemon waits for a prompt before it stops.")
+	daemonEvictTimeout     = flag.Duration("daemon-evict-timeout", 10*time.Minute, "How long the daemon keeps the state of a repository that is not\nprompted for. The repository is no longer watched once evicted.")
 )
 
 func header() string {
@@ -48,6 +87,343 @@ func header() string {
 	return sb.String()
 }
 
+// parseArgs parses the command line flags and returns the remaining
+// arguments. Unlike flag.Parse, flags may follow the arguments, e.g.,
+// git-prompt-string init bash --config=NONE.
+func parseArgs() []string {
+	var args []string
+	for flag.Parse(); flag.NArg() > 0; flag.CommandLine.Parse(flag.Args()[1:]) {
+		args = append(args, flag.Arg(0))
+	}
+	return args
+}
+
+// initShell prints the integration for the prompt of the given shell and
+// exits. The visited flags are passed along to git-prompt-string so that the
+// prompt is rendered with the same configuration.
+func initShell(args []string) {
+	if len(args) != 1 {
+		util.ErrMsg("init", fmt.Errorf("expected a shell, one of %s", strings.Join(shell.Shells, ", ")))
+	}
+	var flags []string
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "json", "version", "daemon-idle-timeout", "daemon-evict-timeout":
+			return
+		}
+		flags = append(flags, fmt.Sprintf("--%s=%s", f.Name, f.Value.String()))
+	})
+	snippet, err := shell.Init(args[0], flags)
+	if err != nil {
+		util.ErrMsg("init", err)
+	}
+	fmt.Print(snippet)
+	os.Exit(0)
+}
+
+// configFilePath returns the filepath of the configuration from the config
+// flag, the GIT_PROMPT_STRING_CONFIG environment variable, or the default
+// location in the XDG config directory, in that order. isExplicit reports
+// whether the filepath was set by the flag or the environment variable, in
+// which case the file must exist.
+func configFilePath() (cfgPath string, isExplicit bool) {
+	cfgPath = *configPath
+	if cfgPath == "" {
+		cfgPath = os.Getenv("GIT_PROMPT_STRING_CONFIG")
+	}
+	if cfgPath != "" {
+		return cfgPath, true
+	}
+	xdgConfigHome := os.Getenv("XDG_CONFIG_HOME")
+	if xdgConfigHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			util.ErrMsg("user home", err)
+		}
+		xdgConfigHome = path.Join(home, util.XDGConfigPath)
+	}
+	return path.Join(xdgConfigHome, "git-prompt-string", "config.toml"), false
+}
+
+// configCommand runs a config subcommand and exits, except for config print
+// which returns so that the configuration is printed once it is merged.
+// defaults is the default configuration.
+func configCommand(args []string, defaults config.GitPromptStringConfig) {
+	if len(args) == 0 {
+		util.ErrMsg("config", fmt.Errorf("expected a subcommand, one of check, print, or init"))
+	}
+	switch args[0] {
+	case "check":
+		checkConfig(args[1:])
+	case "print":
+		if len(args) > 1 {
+			util.ErrMsg("config print", fmt.Errorf("unexpected argument %s", args[1]))
+		}
+	case "init":
+		if len(args) > 1 {
+			util.ErrMsg("config init", fmt.Errorf("unexpected argument %s", args[1]))
+		}
+		initConfig(defaults)
+	default:
+		util.ErrMsg("config", fmt.Errorf("unknown subcommand %s, expected one of check, print, or init", args[0]))
+	}
+}
+
+// printConfig prints cfg as TOML and exits. Each option is annotated with the
+// layer that last set it.
+func printConfig(cfg config.GitPromptStringConfig) {
+	for _, o := range cfg.Options() {
+		line, err := o.TOML()
+		if err != nil {
+			util.ErrMsg("config print", err)
+		}
+		source, ok := cfg.Sources[o.Key]
+		if !ok {
+			source = "default"
+		}
+		fmt.Printf("%s # %s\n", line, source)
+	}
+	os.Exit(0)
+}
+
+// initConfig writes the default configuration to the configuration file if it
+// does not exist and exits. Each option is commented out and preceded by the
+// usage of its flag.
+func initConfig(defaults config.GitPromptStringConfig) {
+	cfgPath, _ := configFilePath()
+	if cfgPath == "NONE" {
+		util.ErrMsg("config init", fmt.Errorf("no configuration file to write"))
+	}
+	if _, err := os.Stat(cfgPath); err == nil {
+		util.ErrMsg("config init", fmt.Errorf("%s already exists", cfgPath))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# git-prompt-string configuration\n")
+	sb.WriteString("# https://github.com/mikesmithgh/git-prompt-string#configuration-options\n")
+	sb.WriteString("#\n")
+	sb.WriteString("# Each option is commented out with its default value. Uncomment an option to\n")
+	sb.WriteString("# change it.\n")
+	for _, o := range defaults.Options() {
+		line, err := o.TOML()
+		if err != nil {
+			util.ErrMsg("config init", err)
+		}
+		sb.WriteString("\n")
+		if f := flag.Lookup(strings.ReplaceAll(o.Key, "_", "-")); f != nil {
+			for _, usage := range strings.Split(strings.TrimSpace(f.Usage), "\n") {
+				sb.WriteString(strings.TrimSpace("# "+usage) + "\n")
+			}
+		}
+		sb.WriteString("# " + line + "\n")
+	}
+
+	err := os.MkdirAll(path.Dir(cfgPath), 0o755)
+	if err != nil {
+		util.ErrMsg("config init", err)
+	}
+	err = os.WriteFile(cfgPath, []byte(sb.String()), 0o644) //nolint:gosec // the configuration is not sensitive
+	if err != nil {
+		util.ErrMsg("config init", err)
+	}
+	fmt.Printf("created %s\n", cfgPath)
+	os.Exit(0)
+}
+
+// checkConfig prints the problems of the configuration file at the given
+// path, or the configuration file that git-prompt-string reads if no path is
+// given, and exits with 1 if there are any problems.
+func checkConfig(args []string) {
+	if len(args) > 1 {
+		util.ErrMsg("config check", fmt.Errorf("expected at most one path, got %d", len(args)))
+	}
+	var cfgPath string
+	if len(args) == 1 {
+		cfgPath = args[0]
+	} else {
+		cfgPath, _ = configFilePath()
+	}
+	if cfgPath == "NONE" {
+		util.ErrMsg("config check", fmt.Errorf("no configuration file to check"))
+	}
+	cfgBytes, err := os.ReadFile(cfgPath)
+	if err != nil {
+		util.ErrMsg("read config", err)
+	}
+	problems := config.Check(cfgBytes)
+	for _, p := range problems {
+		if p.Line == 0 {
+			fmt.Printf("%s: %s\n", cfgPath, p.Message)
+		} else {
+			fmt.Printf("%s:%d:%d: %s\n", cfgPath, p.Line, p.Column, p.Message)
+		}
+	}
+	if len(problems) == 0 {
+		fmt.Printf("%s: ok\n", cfgPath)
+	}
+
+	// the git config variables are checked as well unless another file is
+	// checked, since the prompt ignores unknown variables
+	hasProblems := len(problems) > 0
+	if len(args) == 0 {
+		hasProblems = checkGitConfig() || hasProblems
+	}
+	if hasProblems {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// checkGitConfig prints the problems of the git config variables in the
+// prompt-string section of the repository in the current directory and
+// reports whether there are any. Nothing is checked outside of a repository.
+func checkGitConfig() bool {
+	ctx := context.Background()
+	g, _, err := git.RevParse(ctx)
+	if err != nil {
+		return false
+	}
+	vars, err := g.ConfigRegexp(ctx, `^`+regexp.QuoteMeta(config.GitConfigSection)+`\.`)
+	if err != nil {
+		util.ErrMsg("git config", err)
+	}
+	problems := config.CheckGitConfig(vars)
+	for _, p := range problems {
+		fmt.Println(p.Message)
+	}
+	return len(problems) > 0
+}
+
+// applyEnvAndFlags sets the options of cfg to the values of the environment
+// variables, then to the values of the flags that were passed on the command
+// line. Each flag sets the option with the same name, e.g., --color-dirty sets
+// color_dirty.
+func applyEnvAndFlags(cfg *config.GitPromptStringConfig) {
+	err := cfg.MergeEnv(os.LookupEnv)
+	if err != nil {
+		util.ErrMsg("env", err)
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "config", "json", "version", "daemon-idle-timeout", "daemon-evict-timeout":
+			return
+		}
+		key := strings.ReplaceAll(f.Name, "-", "_")
+		err := cfg.Set(key, f.Value.String())
+		if err != nil {
+			util.ErrMsg("flag", err)
+		}
+		cfg.SetSource(key, "flag --"+f.Name)
+	})
+}
+
+// isFlagSet reports whether the flag name is set on the command line.
+func isFlagSet(name string) bool {
+	isSet := false
+	flag.Visit(func(f *flag.Flag) {
+		isSet = isSet || f.Name == name
+	})
+	return isSet
+}
+
+// runDaemon serves the state of repositories to git-prompt-string on a Unix
+// socket until it is idle or interrupted and exits.
+func runDaemon(args []string) {
+	if len(args) > 0 {
+		util.ErrMsg("daemon", fmt.Errorf("unexpected argument %s", args[0]))
+	}
+	socketPath, err := daemon.SocketPath()
+	if err != nil {
+		util.ErrMsg("daemon", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = daemon.ListenAndServe(ctx, socketPath, &daemon.Server{
+		Version:      version,
+		IdleTimeout:  *daemonIdleTimeout,
+		EvictTimeout: *daemonEvictTimeout,
+	})
+	if err != nil {
+		util.ErrMsg("daemon", err)
+	}
+	os.Exit(0)
+}
+
+// daemonEnv are the environment variables that change how git finds or reads
+// a repository. The daemon runs git with its own environment, so it is not
+// asked when any of them are set.
+var daemonEnv = []string{
+	"GIT_DIR", "GIT_WORK_TREE", "GIT_COMMON_DIR", "GIT_INDEX_FILE", "GIT_OBJECT_DIRECTORY",
+	"GIT_ALTERNATE_OBJECT_DIRECTORIES", "GIT_CEILING_DIRECTORIES", "GIT_DISCOVERY_ACROSS_FILESYSTEM",
+	"GIT_NAMESPACE", "GIT_CONFIG", "GIT_CONFIG_PARAMETERS", "GIT_CONFIG_COUNT", "GIT_CONFIG_GLOBAL",
+	"GIT_CONFIG_SYSTEM", "GIT_CONFIG_NOSYSTEM",
+}
+
+// queryDaemon returns the collected state of the repository in the current
+// directory from the daemon, or nil if the daemon is not running or cannot
+// answer, in which case the state is collected by running git directly.
+func queryDaemon(ctx context.Context) *git.GitRepo {
+	for _, key := range daemonEnv {
+		if _, found := os.LookupEnv(key); found {
+			return nil
+		}
+	}
+	socketPath, err := daemon.SocketPath()
+	if err != nil {
+		return nil
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	g, err := daemon.Query(ctx, socketPath, version, dir)
+	if err != nil {
+		return nil
+	}
+	return g
+}
+
+// timeoutContext returns a context that is done once timeout has elapsed since
+// start. The context is never done if timeout is empty or not positive.
+func timeoutContext(start time.Time, timeout string) (context.Context, context.CancelFunc) {
+	ctx := context.Background()
+	if timeout == "" {
+		return ctx, func() {}
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		util.ErrMsg("parse timeout", err)
+	}
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, start.Add(d))
+}
+
+// mergeRepoConfig layers the configuration of the repository over cfg. The
+// git config variables in the prompt-string section are merged first, then
+// the configuration file in the git directory. The [[match]] blocks are
+// applied afterwards by ApplyMatches.
+func mergeRepoConfig(ctx context.Context, g *git.GitRepo, cfg *config.GitPromptStringConfig) {
+	vars, err := g.ConfigRegexp(ctx, `^`+regexp.QuoteMeta(config.GitConfigSection)+`\.`)
+	if err != nil && !git.TimedOut(ctx, err) {
+		util.ErrMsg("git config", err)
+	}
+	err = cfg.MergeGitConfig(vars)
+	if err != nil {
+		util.ErrMsg("repo config", err)
+	}
+
+	cfgBytes, err := os.ReadFile(g.CommonDirPath(config.RepoFileName))
+	if err != nil && !os.IsNotExist(err) {
+		util.ErrMsg("read repo config", err)
+	}
+	err = cfg.Merge(cfgBytes, "file "+g.CommonDirPath(config.RepoFileName))
+	if err != nil {
+		util.ErrMsg("unmarshal repo config", err)
+	}
+}
+
 func main() {
 	cfg := config.GitPromptStringConfig{
 		PromptPrefix:           *promptPrefix,
@@ -57,12 +433,43 @@ func main() {
 		DivergedFormat:         *divergedFormat,
 		NoUpstreamRemoteFormat: *noUpstreamRemoteFormat,
 		ColorDisabled:          *colorDisabled,
+		Color:                  *colorPolicy,
 		ColorClean:             *colorClean,
 		ColorDelta:             *colorDelta,
 		ColorDirty:             *colorDirty,
 		ColorUntracked:         *colorUntracked,
 		ColorNoUpstream:        *colorNoUpstream,
 		ColorMerging:           *colorMerging,
+		ColorPrefix:            *colorPrefix,
+		ColorBranch:            *colorBranch,
+		ColorMergeState:        *colorMergeState,
+		ColorSparse:            *colorSparse,
+		ColorAheadBehind:       *colorAheadBehind,
+		ColorDirtyMarker:       *colorDirtyMarker,
+		ColorSuffix:            *colorSuffix,
+		Format:                 *format,
+		CountChanges:           *countChanges,
+		StagedFormat:           *stagedFormat,
+		ModifiedFormat:         *modifiedFormat,
+		DeletedFormat:          *deletedFormat,
+		RenamedFormat:          *renamedFormat,
+		UntrackedFormat:        *untrackedFormat,
+		ConflictedFormat:       *conflictedFormat,
+		StashFormat:            *stashFormat,
+		ColorStash:             *colorStash,
+		Timeout:                *timeout,
+		TimeoutMarker:          *timeoutMarker,
+		ColorTimeout:           *colorTimeout,
+		Shell:                  *shellFlag,
+		ColorDepth:             *colorDepth,
+		Output:                 *outputFlag,
+		Powerline:              *powerline,
+		PowerlineSeparator:     *powerlineSeparator,
+		PowerlineThinSeparator: *powerlineThinSeparator,
+		BranchMaxLength:        *branchMaxLength,
+		BranchTruncation:       *branchTruncation,
+		BranchEllipsis:         *branchEllipsis,
+		BranchAbbreviate:       *branchAbbreviate,
 	}
 
 	flag.Usage = func() {
@@ -74,11 +481,52 @@ func main() {
 		sb.WriteString("Usage:")
 		sb.WriteString("\n")
 		sb.WriteString("git-prompt-string [flags]")
+		sb.WriteString("\n")
+		sb.WriteString("git-prompt-string init <shell> [flags]")
+		sb.WriteString("\n")
+		sb.WriteString("git-prompt-string config check [path] [flags]")
+		sb.WriteString("\n")
+		sb.WriteString("git-prompt-string config print [flags]")
+		sb.WriteString("\n")
+		sb.WriteString("git-prompt-string config init [flags]")
+		sb.WriteString("\n")
+		sb.WriteString("git-prompt-string daemon [flags]")
+		sb.WriteString("\n\n")
+		sb.WriteString("Commands:")
+		sb.WriteString("\n")
+		sb.WriteString("  init <shell>\n")
+		sb.WriteString("    \tPrint the integration of git-prompt-string for the prompt of the\n")
+		sb.WriteString("    \tshell. The flags are passed to git-prompt-string when rendering\n")
+		sb.WriteString("    \tthe prompt. The shell is one of " + strings.Join(shell.Shells, ", ") + ".")
+		sb.WriteString("\n")
+		sb.WriteString("  config check [path]\n")
+		sb.WriteString("    \tValidate the configuration file at path, or the configuration file\n")
+		sb.WriteString("    \tof git-prompt-string if path is omitted. The line and column of each\n")
+		sb.WriteString("    \tsyntax error, unknown option, invalid color, and format with the\n")
+		sb.WriteString("    \twrong number of %v verbs are printed.\n")
+		sb.WriteString("  config print\n")
+		sb.WriteString("    \tPrint the effective configuration in the current directory as TOML.\n")
+		sb.WriteString("    \tEach option is annotated with where its value came from, e.g., the\n")
+		sb.WriteString("    \tdefault, a configuration file, git config, the environment, or a flag.\n")
+		sb.WriteString("  config init\n")
+		sb.WriteString("    \tWrite the default configuration with a comment describing each\n")
+		sb.WriteString("    \toption to the configuration file if it does not exist.\n")
+		sb.WriteString("  daemon\n")
+		sb.WriteString("    \tCache the state of repositories and answer git-prompt-string from the\n")
+		sb.WriteString("    \tcache on a Unix socket in $XDG_RUNTIME_DIR. Repositories are watched\n")
+		sb.WriteString("    \tfor changes with inotify, which requires Linux. git-prompt-string runs\n")
+		sb.WriteString("    \tgit itself when the daemon is not running.")
 		sb.WriteString("\n\n")
 		sb.WriteString("Flags can be prefixed with either - or --. For example, -version and")
 		sb.WriteString("\n")
 		sb.WriteString("--version are both valid flags.")
 		sb.WriteString("\n\n")
+		sb.WriteString("Each flag that sets an option can also be set with an environment variable")
+		sb.WriteString("\n")
+		sb.WriteString("named after the flag in upper case with underscores and the prefix")
+		sb.WriteString("\n")
+		sb.WriteString("GIT_PROMPT_STRING_, e.g., GIT_PROMPT_STRING_COLOR_DIRTY. Flags take precedence.")
+		sb.WriteString("\n\n")
 		sb.WriteString("Flags:")
 		sb.WriteString("\n")
 		fmt.Fprint(w, sb.String())
@@ -86,81 +534,41 @@ func main() {
 		flag.PrintDefaults()
 	}
 
-	flag.Parse()
-
-	var cfgPath string
-	cfgEnv := os.Getenv("GIT_PROMPT_STRING_CONFIG")
-	if *configPath == "" {
-		cfgPath = cfgEnv
-	} else {
-		cfgPath = *configPath
-	}
-	if cfgPath == "" {
-		xdgConfigHome := os.Getenv("XDG_CONFIG_HOME")
-		if xdgConfigHome == "" {
-			home, err := os.UserHomeDir()
-			if err != nil {
-				util.ErrMsg("user home", err)
-			}
-			xdgConfigHome = path.Join(home, util.XDGConfigPath)
+	args := parseArgs()
+	isPrintConfig := false
+	if len(args) > 0 {
+		switch args[0] {
+		case "init":
+			initShell(args[1:])
+		case "daemon":
+			runDaemon(args[1:])
+		case "config":
+			configCommand(args[1:], cfg)
+			// config print returns to print the configuration once it is merged
+			isPrintConfig = true
+		default:
+			util.ErrMsg("command", fmt.Errorf("unknown command %s", args[0]))
 		}
-		cfgPath = path.Join(xdgConfigHome, "git-prompt-string", "config.toml")
 	}
 
+	cfgPath, isExplicit := configFilePath()
 	if cfgPath != "NONE" {
 		cfgBytes, err := os.ReadFile(cfgPath)
 		if err != nil && !os.IsNotExist(err) {
 			util.ErrMsg("read config exists", err)
 		}
 
-		if err != nil && (*configPath != "" || cfgEnv != "") {
+		if err != nil && isExplicit {
 			util.ErrMsg("read config", err)
 		}
 
-		err = toml.Unmarshal(cfgBytes, &cfg)
+		err = cfg.Merge(cfgBytes, "file "+cfgPath)
 		if err != nil {
 			util.ErrMsg("unmarshal config", err)
 		}
 	}
 
-	flag.Visit(func(f *flag.Flag) {
-		switch f.Name {
-		case "prompt-prefix":
-			cfg.PromptPrefix = f.Value.String()
-		case "prompt-suffix":
-			cfg.PromptSuffix = f.Value.String()
-		case "ahead-format":
-			cfg.AheadFormat = f.Value.String()
-		case "behind-format":
-			cfg.BehindFormat = f.Value.String()
-		case "diverged-format":
-			cfg.DivergedFormat = f.Value.String()
-		case "no-upstream-remote-format":
-			cfg.NoUpstreamRemoteFormat = f.Value.String()
-		case "color-disabled":
-			colorDisabled, err := strconv.ParseBool(f.Value.String())
-			if err != nil {
-				util.ErrMsg("parse color disabled", err)
-			}
-			cfg.ColorDisabled = colorDisabled
-		case "color-clean":
-			cfg.ColorClean = f.Value.String()
-		case "color-delta":
-			cfg.ColorDelta = f.Value.String()
-		case "color-dirty":
-			cfg.ColorDirty = f.Value.String()
-		case "color-untracked":
-			cfg.ColorUntracked = f.Value.String()
-		case "color-no-upstream":
-			cfg.ColorNoUpstream = f.Value.String()
-		case "color-merging":
-			cfg.ColorMerging = f.Value.String()
-		}
-	})
-
-	if cfg.ColorDisabled {
-		color.Disable()
-	}
+	applyEnvAndFlags(&cfg)
 
 	if *versionFlag {
 		fmt.Print(header())
@@ -170,20 +578,115 @@ func main() {
 		os.Exit(0)
 	}
 
-	resetColor, err := color.Color("reset")
+	start := time.Now()
+	ctx, cancel := timeoutContext(start, cfg.Timeout)
+	defer cancel()
+
+	var err error
+	gitRepo := queryDaemon(ctx)
+	isCollected := gitRepo != nil
+	if isCollected {
+		if gitRepo.IsInGitDir == nil && !isPrintConfig {
+			os.Exit(0)
+		}
+	} else {
+		gitRepo, _, err = git.RevParse(ctx)
+		if err != nil {
+			switch {
+			case strings.Contains(err.Error(), exec.ErrNotFound.Error()):
+				util.ErrMsg("rev parse", err)
+			case git.TimedOut(ctx, err):
+				// the repository is found without git and the prompt is
+				// rendered from what is read from the git directory
+				wd, wdErr := os.Getwd()
+				if wdErr != nil {
+					util.ErrMsg("working directory", wdErr)
+				}
+				if gitRepo = git.FindRepo(wd); gitRepo == nil {
+					if !isPrintConfig {
+						os.Exit(0)
+					}
+					gitRepo = &git.GitRepo{}
+				}
+				gitRepo.TimedOut = true
+			case gitRepo.IsInGitDir == nil && !isPrintConfig:
+				os.Exit(0)
+			default:
+				// allow other errors to pass through, the git directory is known
+			}
+		}
+	}
+
+	if cfgPath != "NONE" && gitRepo.IsInGitDir != nil {
+		mergeRepoConfig(ctx, gitRepo, &cfg)
+		err = gitRepo.ReadHead(ctx)
+		if err != nil {
+			util.ErrMsg("read head", err)
+		}
+		err = cfg.ApplyMatches(config.Target{
+			Branch: gitRepo.Branch,
+			Path: func() (string, error) {
+				if gitRepo.IsInBareRepo || *gitRepo.IsInGitDir {
+					return gitRepo.GitDir, nil
+				}
+				return git.ShowToplevel(ctx)
+			},
+			RemoteURLs: func() ([]string, error) {
+				return gitRepo.RemoteURLs(ctx)
+			},
+		})
+		if err != nil && !git.TimedOut(ctx, err) {
+			util.ErrMsg("match", err)
+		}
+		applyEnvAndFlags(&cfg)
+		ctx, cancel = timeoutContext(start, cfg.Timeout)
+		defer cancel()
+	}
+
+	if isPrintConfig {
+		printConfig(cfg)
+	}
+
+	// an explicit --color flag takes precedence over color_disabled from the
+	// configuration and environment, but not over the --color-disabled flag
+	if isFlagSet("color") && !isFlagSet("color-disabled") {
+		cfg.ColorDisabled = false
+	}
+	if !cfg.ColorDisabled {
+		enabled, err := color.Enabled(cfg.Color, os.Getenv)
+		if err != nil {
+			util.ErrMsg("color", err)
+		}
+		cfg.ColorDisabled = !enabled
+	}
+	if cfg.ColorDisabled {
+		color.Disable()
+	}
+
+	err = color.SetShell(cfg.Shell)
 	if err != nil {
-		util.ErrMsg("color reset", err)
+		util.ErrMsg("shell", err)
 	}
 
-	gitRepo, _, err := git.RevParse()
+	err = color.SetOutput(cfg.Output)
 	if err != nil {
-		switch {
-		case strings.Contains(err.Error(), exec.ErrNotFound.Error()):
-			util.ErrMsg("rev parse", err)
-		case gitRepo.IsInGitDir == nil:
-			os.Exit(0)
-		default:
-			// allow other errors to pass through, the git repo may not have upstream
+		util.ErrMsg("output", err)
+	}
+
+	if cfg.ColorDepth == "auto" {
+		color.SetDepth(color.DetectDepth(os.Getenv("COLORTERM"), os.Getenv("TERM")))
+	} else {
+		depth, err := color.ParseDepth(cfg.ColorDepth)
+		if err != nil {
+			util.ErrMsg("color depth", err)
+		}
+		color.SetDepth(depth)
+	}
+
+	if !isCollected {
+		err = gitRepo.Collect(ctx)
+		if err != nil {
+			util.ErrMsg("collect", err)
 		}
 	}
 
@@ -197,27 +700,33 @@ func main() {
 	}
 
 	if *jsonFormat {
-		color := ""
-		if !cfg.ColorDisabled {
-			color = statusColor
-		}
-		output := map[string]string{
-			"branchInfo":   branchInfo,
-			"branchStatus": branchStatus,
-			"promptPrefix": cfg.PromptPrefix,
-			"promptSuffix": cfg.PromptSuffix,
-			"color":        color,
-		}
+		output := prompt.NewJSON(gitRepo, cfg, branchInfo, branchStatus, statusColor)
 		jsonOutput, err := json.MarshalIndent(output, "", "  ")
 		if err != nil {
 			util.ErrMsg("marshal json", err)
 		}
 		fmt.Print(string(jsonOutput))
+	} else if cfg.Output == "env" {
+		output, err := prompt.Env(gitRepo, cfg.Shell)
+		if err != nil {
+			util.ErrMsg("env", err)
+		}
+		fmt.Print(output)
+	} else if cfg.Powerline {
+		output, err := prompt.Powerline(gitRepo, cfg, statusColor)
+		if err != nil {
+			util.ErrMsg("powerline", err)
+		}
+		fmt.Print(output)
 	} else {
-		promptColor, err := color.Color(strings.Split(statusColor, " ")...)
+		data, err := prompt.NewData(gitRepo, cfg, statusColor)
 		if err != nil {
 			util.ErrMsg("prompt color", err)
 		}
-		fmt.Printf("%s%s%s%s%s%s", promptColor, cfg.PromptPrefix, branchInfo, branchStatus, cfg.PromptSuffix, resetColor)
+		output, err := prompt.Render(cfg.Format, data)
+		if err != nil {
+			util.ErrMsg("format", err)
+		}
+		fmt.Print(output)
 	}
 }
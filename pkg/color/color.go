@@ -12,6 +12,23 @@ const (
 
 var enabled bool = true
 
+// nonPrinting are the delimiters that each shell requires around escape
+// sequences so that they are not counted towards the width of the prompt.
+var nonPrinting = map[string][2]string{
+	"bash":       {`\[`, `\]`},
+	"readline":   {"\x01", "\x02"},
+	"zsh":        {"%{", "%}"},
+	"tcsh":       {"%{", "%}"},
+	"fish":       {"", ""},
+	"powershell": {"", ""},
+	"raw":        {"", ""},
+}
+
+var (
+	shell      string
+	delimiters [2]string
+)
+
 func codeToEscapeSequence(n int) string {
 	return fmt.Sprintf("%s[%dm", esc, n)
 }
@@ -72,6 +89,24 @@ var standardColors = map[string]string{
 	"bg:bright-white":   codeToEscapeSequence(107),
 
 	"reset": codeToEscapeSequence(0),
+
+	"bold":          codeToEscapeSequence(1),
+	"dim":           codeToEscapeSequence(2),
+	"italic":        codeToEscapeSequence(3),
+	"underline":     codeToEscapeSequence(4),
+	"blink":         codeToEscapeSequence(5),
+	"reverse":       codeToEscapeSequence(7),
+	"hidden":        codeToEscapeSequence(8),
+	"strikethrough": codeToEscapeSequence(9),
+
+	"no-bold":          codeToEscapeSequence(22),
+	"no-dim":           codeToEscapeSequence(22),
+	"no-italic":        codeToEscapeSequence(23),
+	"no-underline":     codeToEscapeSequence(24),
+	"no-blink":         codeToEscapeSequence(25),
+	"no-reverse":       codeToEscapeSequence(27),
+	"no-hidden":        codeToEscapeSequence(28),
+	"no-strikethrough": codeToEscapeSequence(29),
 }
 
 func hexToRGB(hex string) (int, int, int, error) {
@@ -81,8 +116,11 @@ func hexToRGB(hex string) (int, int, int, error) {
 
 	hex = strings.TrimPrefix(hex, "#")
 
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
 	if len(hex) != 6 {
-		return 0, 0, 0, fmt.Errorf("hex must be 6 digits, got %s", hex)
+		return 0, 0, 0, fmt.Errorf("hex must be 3 or 6 digits, got %s", hex)
 	}
 	// Parse the hex string into RGB components
 	r, err := strconv.ParseInt(hex[0:2], 16, 32)
@@ -101,7 +139,44 @@ func hexToRGB(hex string) (int, int, int, error) {
 	return int(r), int(g), int(b), nil
 }
 
+func rgbFuncToRGB(rgb string) (int, int, int, error) {
+	args, found := strings.CutPrefix(rgb, "rgb(")
+	args, closed := strings.CutSuffix(args, ")")
+	parts := strings.Split(args, ",")
+	if !found || !closed || len(parts) != 3 {
+		return 0, 0, 0, fmt.Errorf("rgb must be of the form rgb(r,g,b), got %s", rgb)
+	}
+	var values [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 0 || n > 255 {
+			return 0, 0, 0, fmt.Errorf("rgb values must be between 0 and 255, got %s", rgb)
+		}
+		values[i] = n
+	}
+	return values[0], values[1], values[2], nil
+}
+
+func paletteToEscapeSequence(n int, isBg bool) string {
+	if depth < Depth256 {
+		return standardToEscapeSequence(rgbTo16(paletteToRGB(n)), isBg)
+	}
+	var colorType string
+	if isBg {
+		colorType = "48"
+	} else {
+		colorType = "38"
+	}
+	return fmt.Sprintf("\x1b[%s;5;%dm", colorType, n)
+}
+
 func rgbToEscapeSequence(r, g, b int, isBg bool) string {
+	switch depth {
+	case Depth256:
+		return paletteToEscapeSequence(rgbTo256(r, g, b), isBg)
+	case Depth16, DepthNone:
+		return standardToEscapeSequence(rgbTo16(r, g, b), isBg)
+	}
 	var colorType string
 	if isBg {
 		colorType = "48"
@@ -115,25 +190,129 @@ func Disable() {
 	enabled = false
 }
 
+// SetShell wraps each escape sequence returned by Color in the non-printing
+// delimiters of shell and sets the characters of shell that Escape escapes.
+func SetShell(sh string) error {
+	if err := CheckShell(sh); err != nil {
+		return err
+	}
+	shell = sh
+	delimiters = nonPrinting[sh]
+	return nil
+}
+
+// CheckShell returns an error if SetShell does not support sh.
+func CheckShell(sh string) error {
+	if _, exists := nonPrinting[sh]; !exists {
+		return fmt.Errorf("shell %s not supported", sh)
+	}
+	return nil
+}
+
+// Escape returns text with the characters that the output interprets escaped,
+// so that text such as a branch name is displayed as is. The # of tmux starts
+// markup and is escaped as ##. In a prompt, the % of zsh and tcsh starts a
+// prompt sequence and is escaped as %%, and the \ of bash starts a backslash
+// escape and is escaped as \\.
+func Escape(text string) string {
+	switch {
+	case output == "tmux":
+		return strings.ReplaceAll(text, "#", "##")
+	case output != "prompt":
+		return text
+	case shell == "zsh", shell == "tcsh":
+		return strings.ReplaceAll(text, "%", "%%")
+	case shell == "bash":
+		return strings.ReplaceAll(text, `\`, `\\`)
+	}
+	return text
+}
+
 func Color(colors ...string) (string, error) {
 	seq := ""
-	if !enabled {
+	if !enabled || depth == DepthNone {
+		return seq, nil
+	}
+	switch output {
+	case "tmux":
+		return tmuxColor(colors)
+	case "env":
 		return seq, nil
 	}
 	for _, color := range colors {
-		if strings.HasPrefix(color, "#") || strings.HasPrefix(color, "fg:#") || strings.HasPrefix(color, "bg:#") {
-			r, g, b, err := hexToRGB(strings.TrimPrefix(strings.TrimPrefix(color, "fg:"), "bg:"))
-			if err != nil {
-				return "", err
-			}
-			seq += rgbToEscapeSequence(r, g, b, strings.HasPrefix(color, "bg:#"))
-		} else {
-			s, exists := standardColors[color]
-			if !exists {
-				return "", fmt.Errorf("color %s not found", color)
-			}
-			seq += s
+		s, err := escapeSequence(color)
+		if err != nil {
+			return "", err
 		}
+		seq += s
+	}
+	if seq != "" {
+		seq = delimiters[0] + seq + delimiters[1]
 	}
 	return seq, nil
 }
+
+// escapeSequence returns the escape sequence of a single color. The color is
+// a standard color or style, a hex color, an rgb() color, a palette index, or
+// a named color, optionally prefixed with fg: or bg:.
+func escapeSequence(color string) (string, error) {
+	if s, exists := standardColors[color]; exists {
+		return s, nil
+	}
+
+	value, isBg := strings.CutPrefix(color, "bg:")
+	if !isBg {
+		value = strings.TrimPrefix(color, "fg:")
+	}
+
+	switch {
+	case strings.HasPrefix(value, "#"):
+		r, g, b, err := hexToRGB(value)
+		if err != nil {
+			return "", err
+		}
+		return rgbToEscapeSequence(r, g, b, isBg), nil
+	case strings.HasPrefix(value, "rgb("):
+		r, g, b, err := rgbFuncToRGB(value)
+		if err != nil {
+			return "", err
+		}
+		return rgbToEscapeSequence(r, g, b, isBg), nil
+	}
+
+	if n, err := strconv.Atoi(value); err == nil {
+		if n < 0 || n > 255 {
+			return "", fmt.Errorf("color index must be between 0 and 255, got %s", color)
+		}
+		return paletteToEscapeSequence(n, isBg), nil
+	}
+
+	if rgb, exists := namedColors[value]; exists {
+		return rgbToEscapeSequence(rgb[0], rgb[1], rgb[2], isBg), nil
+	}
+
+	return "", fmt.Errorf("color %s not found", color)
+}
+
+// Segment wraps text in the escape sequence of colors. The colors are reset
+// before and after text, then the escape sequence of restore is applied so
+// that the colors of the surrounding text are unaffected. The text is returned
+// unchanged if it is empty or no colors are provided.
+func Segment(text string, colors []string, restore []string) (string, error) {
+	if !enabled || depth == DepthNone || text == "" || len(colors) == 0 {
+		return text, nil
+	}
+	reset, err := Color("reset")
+	if err != nil {
+		return "", err
+	}
+	start, err := Color(colors...)
+	if err != nil {
+		return "", err
+	}
+	end, err := Color(restore...)
+	if err != nil {
+		return "", err
+	}
+	return reset + start + text + reset + end, nil
+}
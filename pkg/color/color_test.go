@@ -0,0 +1,74 @@
+package color
+
+import (
+	"testing"
+)
+
+func TestColor(t *testing.T) {
+	tests := []struct {
+		name     string
+		colors   []string
+		expected string
+		err      string
+	}{
+		// standard colors and styles
+		{"standard", []string{"red"}, "\x1b[31m", ""},
+		{"standard fg", []string{"fg:bright-red"}, "\x1b[91m", ""},
+		{"standard bg", []string{"bg:blue"}, "\x1b[44m", ""},
+		{"style", []string{"bold", "no-bold"}, "\x1b[1m\x1b[22m", ""},
+		{"not found", []string{"red", "nope"}, "", "color nope not found"},
+
+		// hex
+		{"hex", []string{"#e6ee04"}, "\x1b[38;2;230;238;4m", ""},
+		{"hex fg", []string{"fg:#e6ee04"}, "\x1b[38;2;230;238;4m", ""},
+		{"hex bg", []string{"bg:#E6EE04"}, "\x1b[48;2;230;238;4m", ""},
+		{"hex short", []string{"#f80"}, "\x1b[38;2;255;136;0m", ""},
+		{"hex short bg", []string{"bg:#abc"}, "\x1b[48;2;170;187;204m", ""},
+		{"hex length", []string{"#ff00"}, "", "hex must be 3 or 6 digits, got ff00"},
+		{"hex digits", []string{"#ggg"}, "", `strconv.ParseInt: parsing "gg": invalid syntax`},
+
+		// rgb
+		{"rgb", []string{"rgb(255,136,0)"}, "\x1b[38;2;255;136;0m", ""},
+		{"rgb fg", []string{"fg:rgb(0,0,0)"}, "\x1b[38;2;0;0;0m", ""},
+		{"rgb bg", []string{"bg:rgb(32,32,32)"}, "\x1b[48;2;32;32;32m", ""},
+		{"rgb range", []string{"rgb(256,0,0)"}, "", "rgb values must be between 0 and 255, got rgb(256,0,0)"},
+		{"rgb not a number", []string{"rgb(a,0,0)"}, "", "rgb values must be between 0 and 255, got rgb(a,0,0)"},
+		{"rgb missing value", []string{"rgb(0,0)"}, "", "rgb must be of the form rgb(r,g,b), got rgb(0,0)"},
+		{"rgb unclosed", []string{"rgb(0,0,0"}, "", "rgb must be of the form rgb(r,g,b), got rgb(0,0,0"},
+
+		// palette
+		{"palette", []string{"208"}, "\x1b[38;5;208m", ""},
+		{"palette fg", []string{"fg:0"}, "\x1b[38;5;0m", ""},
+		{"palette bg", []string{"bg:236"}, "\x1b[48;5;236m", ""},
+		{"palette range", []string{"fg:256"}, "", "color index must be between 0 and 255, got fg:256"},
+		{"palette negative", []string{"bg:-1"}, "", "color index must be between 0 and 255, got bg:-1"},
+
+		// named
+		{"named", []string{"forestgreen"}, "\x1b[38;2;34;139;34m", ""},
+		{"named fg", []string{"fg:rebeccapurple"}, "\x1b[38;2;102;51;153m", ""},
+		{"named bg", []string{"bg:lightgrey"}, "\x1b[48;2;211;211;211m", ""},
+		{"named standard precedence", []string{"green", "fg:green", "bg:green"}, "\x1b[32m\x1b[32m\x1b[42m", ""},
+		{"named style prefix", []string{"bg:bold"}, "", "color bg:bold not found"},
+
+		// combined
+		{"combined", []string{"bold", "fg:208", "bg:#202020", "underline"}, "\x1b[1m\x1b[38;5;208m\x1b[48;2;32;32;32m\x1b[4m", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := Color(test.colors...)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package color
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Depth is the number of colors that the terminal can display.
+type Depth int
+
+const (
+	DepthNone Depth = iota
+	Depth16
+	Depth256
+	DepthTruecolor
+)
+
+var depth = DepthTruecolor
+
+// SetDepth maps the colors returned by Color to the nearest color that can be
+// displayed at depth d. A depth of DepthNone disables all colors.
+func SetDepth(d Depth) {
+	depth = d
+}
+
+func ParseDepth(s string) (Depth, error) {
+	switch s {
+	case "truecolor", "24bit":
+		return DepthTruecolor, nil
+	case "256":
+		return Depth256, nil
+	case "16":
+		return Depth16, nil
+	case "none":
+		return DepthNone, nil
+	default:
+		return DepthNone, fmt.Errorf("color depth %s not supported", s)
+	}
+}
+
+// DetectDepth returns the color depth of the terminal described by the
+// COLORTERM and TERM environment variables. If neither is set, the terminal
+// is assumed to support truecolor.
+func DetectDepth(colorterm string, term string) Depth {
+	switch {
+	case colorterm == "truecolor" || colorterm == "24bit":
+		return DepthTruecolor
+	case term == "":
+		return DepthTruecolor
+	case term == "dumb":
+		return DepthNone
+	case strings.HasSuffix(term, "-direct"):
+		return DepthTruecolor
+	case strings.Contains(term, "256color"):
+		return Depth256
+	default:
+		return Depth16
+	}
+}
+
+// cubeLevels are the values of each component in the 6x6x6 color cube of the
+// 256 color palette.
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// ansiColors are the xterm defaults of the 16 standard colors, used to find
+// the nearest standard color.
+var ansiColors = [16][3]int{
+	{0, 0, 0},
+	{205, 0, 0},
+	{0, 205, 0},
+	{205, 205, 0},
+	{0, 0, 238},
+	{205, 0, 205},
+	{0, 205, 205},
+	{229, 229, 229},
+	{127, 127, 127},
+	{255, 0, 0},
+	{0, 255, 0},
+	{255, 255, 0},
+	{92, 92, 255},
+	{255, 0, 255},
+	{0, 255, 255},
+	{255, 255, 255},
+}
+
+func distance(r1, g1, b1, r2, g2, b2 int) int {
+	dr, dg, db := r1-r2, g1-g2, b1-b2
+	return dr*dr + dg*dg + db*db
+}
+
+func nearestCubeLevel(v int) int {
+	nearest := 0
+	for i, level := range cubeLevels {
+		if abs(v-level) < abs(v-cubeLevels[nearest]) {
+			nearest = i
+		}
+	}
+	return nearest
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// rgbTo256 returns the index of the nearest color in the color cube or the
+// grayscale ramp of the 256 color palette.
+func rgbTo256(r, g, b int) int {
+	ri, gi, bi := nearestCubeLevel(r), nearestCubeLevel(g), nearestCubeLevel(b)
+	cube := 16 + 36*ri + 6*gi + bi
+	cubeDistance := distance(r, g, b, cubeLevels[ri], cubeLevels[gi], cubeLevels[bi])
+
+	gray := min(max((r+g+b)/3-3, 0)/10, 23)
+	grayLevel := 8 + 10*gray
+	grayDistance := distance(r, g, b, grayLevel, grayLevel, grayLevel)
+
+	if grayDistance < cubeDistance {
+		return 232 + gray
+	}
+	return cube
+}
+
+// rgbTo16 returns the index of the nearest standard color.
+func rgbTo16(r, g, b int) int {
+	nearest := 0
+	for i, c := range ansiColors {
+		if distance(r, g, b, c[0], c[1], c[2]) < distance(r, g, b, ansiColors[nearest][0], ansiColors[nearest][1], ansiColors[nearest][2]) {
+			nearest = i
+		}
+	}
+	return nearest
+}
+
+// paletteToRGB returns the components of the color at index n of the 256
+// color palette.
+func paletteToRGB(n int) (int, int, int) {
+	switch {
+	case n < 16:
+		c := ansiColors[n]
+		return c[0], c[1], c[2]
+	case n < 232:
+		n -= 16
+		return cubeLevels[n/36], cubeLevels[n/6%6], cubeLevels[n%6]
+	default:
+		level := 8 + 10*(n-232)
+		return level, level, level
+	}
+}
+
+// standardToEscapeSequence returns the escape sequence of the standard color
+// at index n, where 0-7 are the normal colors and 8-15 the bright colors.
+func standardToEscapeSequence(n int, isBg bool) string {
+	code := 30 + n
+	if n >= 8 {
+		code = 90 + n - 8
+	}
+	if isBg {
+		code += 10
+	}
+	return codeToEscapeSequence(code)
+}
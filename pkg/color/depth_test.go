@@ -0,0 +1,102 @@
+package color
+
+import (
+	"testing"
+)
+
+func TestDetectDepth(t *testing.T) {
+	tests := []struct {
+		colorterm string
+		term      string
+		expected  Depth
+	}{
+		{"truecolor", "xterm", DepthTruecolor},
+		{"24bit", "xterm-256color", DepthTruecolor},
+		{"", "", DepthTruecolor},
+		{"", "xterm-direct", DepthTruecolor},
+		{"", "xterm-256color", Depth256},
+		{"", "tmux-256color", Depth256},
+		{"", "xterm", Depth16},
+		{"", "linux", Depth16},
+		{"", "dumb", DepthNone},
+	}
+
+	for _, test := range tests {
+		actual := DetectDepth(test.colorterm, test.term)
+		if actual != test.expected {
+			t.Errorf("COLORTERM=%q TERM=%q: expected %d, got %d", test.colorterm, test.term, test.expected, actual)
+		}
+	}
+}
+
+func TestParseDepth(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Depth
+		err      string
+	}{
+		{"truecolor", DepthTruecolor, ""},
+		{"24bit", DepthTruecolor, ""},
+		{"256", Depth256, ""},
+		{"16", Depth16, ""},
+		{"none", DepthNone, ""},
+		{"8", DepthNone, "color depth 8 not supported"},
+	}
+
+	for _, test := range tests {
+		actual, err := ParseDepth(test.input)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: expected error %q, got %v", test.input, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.input, err)
+		}
+		if actual != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.input, test.expected, actual)
+		}
+	}
+}
+
+func TestColorDepth(t *testing.T) {
+	t.Cleanup(func() { SetDepth(DepthTruecolor) })
+
+	tests := []struct {
+		depth    Depth
+		colors   []string
+		expected string
+	}{
+		{DepthTruecolor, []string{"#e6ee04", "bg:208"}, "\x1b[38;2;230;238;4m\x1b[48;5;208m"},
+
+		// cube, grayscale, and exact matches
+		{Depth256, []string{"#e6ee04"}, "\x1b[38;5;190m"},
+		{Depth256, []string{"bg:#202020"}, "\x1b[48;5;234m"},
+		{Depth256, []string{"#000000", "#ffffff"}, "\x1b[38;5;16m\x1b[38;5;231m"},
+		{Depth256, []string{"#5f87af"}, "\x1b[38;5;67m"},
+		{Depth256, []string{"forestgreen"}, "\x1b[38;5;28m"},
+		{Depth256, []string{"bg:236", "red", "bold"}, "\x1b[48;5;236m\x1b[31m\x1b[1m"},
+
+		// nearest standard color
+		{Depth16, []string{"#e6ee04"}, "\x1b[93m"},
+		{Depth16, []string{"bg:#b30559"}, "\x1b[41m"},
+		{Depth16, []string{"rgb(0,0,0)", "bg:#fff"}, "\x1b[30m\x1b[107m"},
+		{Depth16, []string{"fg:208", "bg:236"}, "\x1b[33m\x1b[40m"},
+		{Depth16, []string{"fg:3", "bg:12"}, "\x1b[33m\x1b[104m"},
+		{Depth16, []string{"bright-black"}, "\x1b[90m"},
+
+		{DepthNone, []string{"red", "#e6ee04", "bold"}, ""},
+	}
+
+	for _, test := range tests {
+		SetDepth(test.depth)
+		actual, err := Color(test.colors...)
+		if err != nil {
+			t.Errorf("%v at depth %d: unexpected error: %s", test.colors, test.depth, err)
+		}
+		if actual != test.expected {
+			t.Errorf("%v at depth %d: expected %q, got %q", test.colors, test.depth, test.expected, actual)
+		}
+	}
+}
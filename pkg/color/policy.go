@@ -0,0 +1,33 @@
+package color
+
+import "fmt"
+
+// Enabled reports whether colors are enabled for policy, one of auto, always,
+// or never. If policy is auto, the NO_COLOR, CLICOLOR_FORCE, and CLICOLOR
+// environment variables are read with getenv, in that order of precedence.
+// See https://no-color.org and https://bixense.com/clicolors.
+//
+// Unlike most command line tools, auto does not check whether the output is
+// a terminal because prompts are always rendered through a pipe.
+func Enabled(policy string, getenv func(string) string) (bool, error) {
+	switch policy {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+	default:
+		return false, fmt.Errorf("color %s not supported, expected one of auto, always, or never", policy)
+	}
+
+	if getenv("NO_COLOR") != "" {
+		return false, nil
+	}
+	if force := getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return true, nil
+	}
+	if getenv("CLICOLOR") == "0" {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,42 @@
+package color
+
+import (
+	"testing"
+)
+
+func TestEnabled(t *testing.T) {
+	tests := []struct {
+		policy   string
+		environ  map[string]string
+		expected bool
+		err      string
+	}{
+		{"auto", nil, true, ""},
+		{"auto", map[string]string{"NO_COLOR": "1"}, false, ""},
+		{"auto", map[string]string{"NO_COLOR": ""}, true, ""},
+		{"auto", map[string]string{"CLICOLOR": "0"}, false, ""},
+		{"auto", map[string]string{"CLICOLOR": "1"}, true, ""},
+		{"auto", map[string]string{"CLICOLOR": "0", "CLICOLOR_FORCE": "1"}, true, ""},
+		{"auto", map[string]string{"CLICOLOR": "0", "CLICOLOR_FORCE": "0"}, false, ""},
+		{"auto", map[string]string{"NO_COLOR": "1", "CLICOLOR_FORCE": "1"}, false, ""},
+		{"always", map[string]string{"NO_COLOR": "1", "CLICOLOR": "0"}, true, ""},
+		{"never", map[string]string{"CLICOLOR_FORCE": "1"}, false, ""},
+		{"sometimes", nil, false, "color sometimes not supported, expected one of auto, always, or never"},
+	}
+
+	for _, test := range tests {
+		actual, err := Enabled(test.policy, func(key string) string { return test.environ[key] })
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s %v: expected error %q, got %v", test.policy, test.environ, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %v: unexpected error: %s", test.policy, test.environ, err)
+		}
+		if actual != test.expected {
+			t.Errorf("%s %v: expected %t, got %t", test.policy, test.environ, test.expected, actual)
+		}
+	}
+}
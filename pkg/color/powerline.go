@@ -0,0 +1,107 @@
+package color
+
+import (
+	"strings"
+)
+
+// PowerlineSegment is text displayed in colors, e.g., black bg:green.
+type PowerlineSegment struct {
+	Text   string
+	Colors []string
+}
+
+// Background returns colors with the foreground color as the background,
+// e.g., bold bg:green for bold green, so that the color of the prompt can be
+// the background of a segment. Styles are kept and background colors are
+// replaced.
+func Background(colors []string) []string {
+	bg := make([]string, 0, len(colors))
+	for _, color := range colors {
+		// the attributes of tmux are the styles and reset
+		if _, isStyle := tmuxAttributes[color]; isStyle {
+			bg = append(bg, color)
+			continue
+		}
+		if strings.HasPrefix(color, "bg:") {
+			continue
+		}
+		bg = append(bg, "bg:"+strings.TrimPrefix(color, "fg:"))
+	}
+	return bg
+}
+
+// backgroundOf returns the last background color of colors without the bg:
+// prefix, or empty if the background is the default of the terminal.
+func backgroundOf(colors []string) string {
+	bg := ""
+	for _, color := range colors {
+		if value, isBg := strings.CutPrefix(color, "bg:"); isBg {
+			bg = value
+		}
+	}
+	return bg
+}
+
+// Powerline renders the segments that are not empty, each padded with a space
+// on both sides, separated by separator. The foreground of a separator is the
+// background of the segment before it and the background of a separator is
+// the background of the segment after it, so that the separator joins the
+// two segments, e.g., an arrow. Segments with the same background would not
+// be told apart by separator, so they are separated by thinSeparator in the
+// colors of the segment before it instead. The last separator is displayed
+// on the default background.
+func Powerline(segments []PowerlineSegment, separator string, thinSeparator string) (string, error) {
+	reset, err := Color("reset")
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	prev := ""
+	for _, segment := range segments {
+		if segment.Text == "" {
+			continue
+		}
+		start, err := Color(segment.Colors...)
+		if err != nil {
+			return "", err
+		}
+		bg := backgroundOf(segment.Colors)
+		if sb.Len() > 0 && bg == prev {
+			sb.WriteString(thinSeparator)
+		} else if sb.Len() > 0 {
+			s, err := powerlineSeparator(separator, prev, bg)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(s)
+		}
+		sb.WriteString(reset + start + " " + segment.Text + " ")
+		prev = bg
+	}
+	if sb.Len() == 0 {
+		return "", nil
+	}
+	end, err := powerlineSeparator(separator, prev, "")
+	if err != nil {
+		return "", err
+	}
+	return sb.String() + end + reset, nil
+}
+
+// powerlineSeparator returns separator in the foreground color prev on the
+// background color next. Either is the default of the terminal if empty.
+func powerlineSeparator(separator string, prev string, next string) (string, error) {
+	colors := []string{"reset"}
+	if prev != "" {
+		colors = append(colors, "fg:"+prev)
+	}
+	if next != "" {
+		colors = append(colors, "bg:"+next)
+	}
+	start, err := Color(colors...)
+	if err != nil {
+		return "", err
+	}
+	return start + separator, nil
+}
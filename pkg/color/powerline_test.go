@@ -0,0 +1,99 @@
+package color
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBackground(t *testing.T) {
+	tests := []struct {
+		colors   []string
+		expected []string
+	}{
+		{[]string{"green"}, []string{"bg:green"}},
+		{[]string{"bold", "fg:#e6ee04"}, []string{"bold", "bg:#e6ee04"}},
+		{[]string{"bold", "bright-red", "bg:#202020"}, []string{"bold", "bg:bright-red"}},
+		{[]string{"208", "no-italic"}, []string{"bg:208", "no-italic"}},
+		{[]string{}, []string{}},
+	}
+
+	for _, test := range tests {
+		actual := Background(test.colors)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Background(%q): expected %q, got %q", test.colors, test.expected, actual)
+		}
+	}
+}
+
+func TestPowerline(t *testing.T) {
+	t.Cleanup(func() {
+		_ = SetOutput("prompt")
+		SetDepth(DepthTruecolor)
+	})
+
+	green := []string{"black", "bg:green"}
+	blue := []string{"white", "bg:#0000ff"}
+	tests := []struct {
+		name     string
+		output   string
+		depth    Depth
+		segments []PowerlineSegment
+		expected string
+		err      string
+	}{
+		{
+			"single", "prompt", DepthTruecolor,
+			[]PowerlineSegment{{"main", green}},
+			"\x1b[0m\x1b[30m\x1b[42m main \x1b[0m\x1b[32m>\x1b[0m", "",
+		},
+		{
+			"transition", "prompt", DepthTruecolor,
+			[]PowerlineSegment{{"main", green}, {"", blue}, {"↑[1]", blue}},
+			"\x1b[0m\x1b[30m\x1b[42m main \x1b[0m\x1b[32m\x1b[48;2;0;0;255m>\x1b[0m\x1b[37m\x1b[48;2;0;0;255m ↑[1] \x1b[0m\x1b[38;2;0;0;255m>\x1b[0m", "",
+		},
+		{
+			"same background", "prompt", DepthTruecolor,
+			[]PowerlineSegment{{"main", green}, {"*", green}},
+			"\x1b[0m\x1b[30m\x1b[42m main |\x1b[0m\x1b[30m\x1b[42m * \x1b[0m\x1b[32m>\x1b[0m", "",
+		},
+		{
+			"default background", "prompt", DepthTruecolor,
+			[]PowerlineSegment{{"main", []string{"red"}}, {"*", green}},
+			"\x1b[0m\x1b[31m main \x1b[0m\x1b[42m>\x1b[0m\x1b[30m\x1b[42m * \x1b[0m\x1b[32m>\x1b[0m", "",
+		},
+		{
+			"tmux", "tmux", DepthTruecolor,
+			[]PowerlineSegment{{"main", green}, {"↑[1]", blue}},
+			"#[default]#[fg=black,bg=green] main #[default,fg=green,bg=#0000ff]>#[default]#[fg=white,bg=#0000ff] ↑[1] #[default,fg=#0000ff]>#[default]", "",
+		},
+		{
+			"no color", "prompt", DepthNone,
+			[]PowerlineSegment{{"main", green}, {"↑[1]", blue}},
+			" main > ↑[1] >", "",
+		},
+		{"empty", "prompt", DepthTruecolor, []PowerlineSegment{{"", green}}, "", ""},
+		{"not found", "prompt", DepthTruecolor, []PowerlineSegment{{"main", []string{"bg:nope"}}}, "", "color bg:nope not found"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := SetOutput(test.output); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			SetDepth(test.depth)
+			actual, err := Powerline(test.segments, ">", "|")
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package color
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// output is the markup that Color returns, either escape sequences for a
+// prompt, style markup for tmux, or nothing for shell variables.
+var output = "prompt"
+
+// Outputs are the supported values of SetOutput.
+var Outputs = []string{"prompt", "tmux", "env"}
+
+// SetOutput sets the markup that Color returns. If output is prompt, colors
+// are ANSI escape sequences. If output is tmux, colors are the style markup of
+// the tmux status line, e.g., #[fg=red,bold], which tmux does not read from
+// escape sequences. If output is env, the output is evaluated by a shell and
+// Color returns nothing.
+func SetOutput(o string) error {
+	if err := CheckOutput(o); err != nil {
+		return err
+	}
+	output = o
+	return nil
+}
+
+// CheckOutput returns an error if o is not one of Outputs.
+func CheckOutput(o string) error {
+	for _, supported := range Outputs {
+		if o == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("output %s not supported, expected one of %s", o, strings.Join(Outputs, ", "))
+}
+
+// tmuxNames are the tmux names of the standard colors, indexed like
+// ansiColors.
+var tmuxNames = [16]string{
+	"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white",
+	"brightblack", "brightred", "brightgreen", "brightyellow", "brightblue", "brightmagenta", "brightcyan", "brightwhite",
+}
+
+// tmuxAttributes are the tmux attributes of the styles and reset.
+var tmuxAttributes = map[string]string{
+	"reset": "default",
+
+	"bold":          "bold",
+	"dim":           "dim",
+	"italic":        "italics",
+	"underline":     "underscore",
+	"blink":         "blink",
+	"reverse":       "reverse",
+	"hidden":        "hidden",
+	"strikethrough": "strikethrough",
+
+	"no-bold":          "nobold",
+	"no-dim":           "nodim",
+	"no-italic":        "noitalics",
+	"no-underline":     "nounderscore",
+	"no-blink":         "noblink",
+	"no-reverse":       "noreverse",
+	"no-hidden":        "nohidden",
+	"no-strikethrough": "nostrikethrough",
+}
+
+// tmuxColor returns the tmux style markup of colors, e.g., #[fg=red,bold].
+func tmuxColor(colors []string) (string, error) {
+	styles := make([]string, 0, len(colors))
+	for _, color := range colors {
+		s, err := tmuxStyle(color)
+		if err != nil {
+			return "", err
+		}
+		styles = append(styles, s)
+	}
+	if len(styles) == 0 {
+		return "", nil
+	}
+	return "#[" + strings.Join(styles, ",") + "]", nil
+}
+
+// tmuxStyle returns the tmux style of a single color, e.g., fg=red or
+// bg=#e6ee04. The colors are the same as those of escapeSequence and are
+// mapped to the nearest color that can be displayed at the current depth.
+func tmuxStyle(color string) (string, error) {
+	if attr, exists := tmuxAttributes[color]; exists {
+		return attr, nil
+	}
+
+	value, isBg := strings.CutPrefix(color, "bg:")
+	if !isBg {
+		value = strings.TrimPrefix(color, "fg:")
+	}
+	key := "fg="
+	if isBg {
+		key = "bg="
+	}
+
+	for i, name := range tmuxNames {
+		if value == strings.Replace(name, "bright", "bright-", 1) {
+			return key + tmuxNames[i], nil
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(value, "#"):
+		r, g, b, err := hexToRGB(value)
+		if err != nil {
+			return "", err
+		}
+		return key + rgbToTmux(r, g, b), nil
+	case strings.HasPrefix(value, "rgb("):
+		r, g, b, err := rgbFuncToRGB(value)
+		if err != nil {
+			return "", err
+		}
+		return key + rgbToTmux(r, g, b), nil
+	}
+
+	if n, err := strconv.Atoi(value); err == nil {
+		if n < 0 || n > 255 {
+			return "", fmt.Errorf("color index must be between 0 and 255, got %s", color)
+		}
+		return key + paletteToTmux(n), nil
+	}
+
+	if rgb, exists := namedColors[value]; exists {
+		return key + rgbToTmux(rgb[0], rgb[1], rgb[2]), nil
+	}
+
+	return "", fmt.Errorf("color %s not found", color)
+}
+
+func paletteToTmux(n int) string {
+	if depth < Depth256 {
+		return tmuxNames[rgbTo16(paletteToRGB(n))]
+	}
+	return fmt.Sprintf("colour%d", n)
+}
+
+func rgbToTmux(r, g, b int) string {
+	switch depth {
+	case Depth256:
+		return paletteToTmux(rgbTo256(r, g, b))
+	case Depth16, DepthNone:
+		return tmuxNames[rgbTo16(r, g, b)]
+	}
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
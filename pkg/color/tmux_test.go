@@ -0,0 +1,124 @@
+package color
+
+import (
+	"testing"
+)
+
+func TestTmux(t *testing.T) {
+	t.Cleanup(func() {
+		_ = SetOutput("prompt")
+		SetDepth(DepthTruecolor)
+	})
+	if err := SetOutput("tmux"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		depth    Depth
+		colors   []string
+		expected string
+		err      string
+	}{
+		// standard colors and styles
+		{"standard", DepthTruecolor, []string{"red"}, "#[fg=red]", ""},
+		{"standard fg", DepthTruecolor, []string{"fg:bright-red"}, "#[fg=brightred]", ""},
+		{"standard bg", DepthTruecolor, []string{"bg:blue"}, "#[bg=blue]", ""},
+		{"style", DepthTruecolor, []string{"bold", "italic", "underline", "no-bold"}, "#[bold,italics,underscore,nobold]", ""},
+		{"reset", DepthTruecolor, []string{"reset"}, "#[default]", ""},
+		{"empty", DepthTruecolor, []string{}, "", ""},
+		{"not found", DepthTruecolor, []string{"red", "nope"}, "", "color nope not found"},
+		{"style prefix", DepthTruecolor, []string{"bg:bold"}, "", "color bg:bold not found"},
+
+		// truecolor
+		{"hex", DepthTruecolor, []string{"#E6EE04"}, "#[fg=#e6ee04]", ""},
+		{"hex bg", DepthTruecolor, []string{"bg:#abc"}, "#[bg=#aabbcc]", ""},
+		{"hex length", DepthTruecolor, []string{"#ff00"}, "", "hex must be 3 or 6 digits, got ff00"},
+		{"rgb", DepthTruecolor, []string{"fg:rgb(255,136,0)"}, "#[fg=#ff8800]", ""},
+		{"rgb range", DepthTruecolor, []string{"rgb(256,0,0)"}, "", "rgb values must be between 0 and 255, got rgb(256,0,0)"},
+		{"palette", DepthTruecolor, []string{"bg:236"}, "#[bg=colour236]", ""},
+		{"palette range", DepthTruecolor, []string{"fg:256"}, "", "color index must be between 0 and 255, got fg:256"},
+		{"named", DepthTruecolor, []string{"forestgreen"}, "#[fg=#228b22]", ""},
+		{"combined", DepthTruecolor, []string{"bold", "fg:208", "bg:#202020"}, "#[bold,fg=colour208,bg=#202020]", ""},
+
+		// 256 colors
+		{"hex 256", Depth256, []string{"#e6ee04"}, "#[fg=colour190]", ""},
+		{"palette 256", Depth256, []string{"bg:236"}, "#[bg=colour236]", ""},
+
+		// 16 colors
+		{"hex 16", Depth16, []string{"#e6ee04", "bg:#16f2aa"}, "#[fg=brightyellow,bg=cyan]", ""},
+		{"palette 16", Depth16, []string{"9"}, "#[fg=brightred]", ""},
+		{"standard 16", Depth16, []string{"green"}, "#[fg=green]", ""},
+
+		// no color
+		{"none", DepthNone, []string{"bold", "red"}, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetDepth(test.depth)
+			actual, err := Color(test.colors...)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	t.Cleanup(func() { _ = SetOutput("prompt") })
+
+	if err := SetOutput("nope"); err == nil || err.Error() != "output nope not supported, expected one of prompt, tmux, env" {
+		t.Errorf("expected an error for an unsupported output, got %v", err)
+	}
+	if actual := Escape("fix#1"); actual != "fix#1" {
+		t.Errorf("expected the prompt output not to escape #, got %q", actual)
+	}
+	if err := SetOutput("tmux"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if actual := Escape("fix#1#"); actual != "fix##1##" {
+		t.Errorf("expected # to be escaped as ##, got %q", actual)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	t.Cleanup(func() {
+		_ = SetOutput("prompt")
+		_ = SetShell("raw")
+	})
+
+	tests := []struct {
+		output   string
+		shell    string
+		expected string
+	}{
+		{"prompt", "zsh", `fix%%1\#`},
+		{"prompt", "tcsh", `fix%%1\#`},
+		{"prompt", "bash", `fix%1\\#`},
+		{"prompt", "readline", `fix%1\#`},
+		{"prompt", "fish", `fix%1\#`},
+		{"tmux", "zsh", `fix%1\##`},
+		{"env", "bash", `fix%1\#`},
+	}
+	for _, test := range tests {
+		if err := SetOutput(test.output); err != nil {
+			t.Fatal(err)
+		}
+		if err := SetShell(test.shell); err != nil {
+			t.Fatal(err)
+		}
+		if actual := Escape(`fix%1\#`); actual != test.expected {
+			t.Errorf("%s %s: expected %q, got %q", test.output, test.shell, test.expected, actual)
+		}
+	}
+}
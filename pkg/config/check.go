@@ -0,0 +1,283 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mikesmithgh/git-prompt-string/pkg/color"
+	"github.com/mikesmithgh/git-prompt-string/pkg/util"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// verbCounts are the number of verbs that each format option requires.
+var verbCounts = map[string]int{
+	"ahead_format":              1,
+	"behind_format":             1,
+	"diverged_format":           2,
+	"no_upstream_remote_format": 2,
+	"staged_format":             1,
+	"modified_format":           1,
+	"deleted_format":            1,
+	"renamed_format":            1,
+	"untracked_format":          1,
+	"conflicted_format":         1,
+	"stash_format":              1,
+}
+
+// typeErrRegexp matches the error of go-toml when a value cannot be decoded
+// into the type of an option, e.g., cannot decode TOML integer into struct
+// field ... of type string.
+var typeErrRegexp = regexp.MustCompile(`^cannot decode (TOML \w+) into .* of type (\S+)$`)
+
+// Problem is an error in a configuration file. Line and Column are 1-indexed
+// and zero if the location of the error is unknown.
+type Problem struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+// Check validates the TOML document data and returns its problems in the
+// order they appear in the document. Unknown options, invalid colors, formats
+// with the wrong number of verbs, and unsupported values, e.g., of shell or
+// timeout, are reported in addition to syntax errors and options of the wrong
+// type.
+func Check(data []byte) []Problem {
+	var doc struct {
+		GitPromptStringConfig
+		Match []map[string]any `toml:"match"`
+	}
+	decoder := toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields()
+	err := decoder.Decode(&doc)
+
+	var (
+		problems  []Problem
+		strictErr *toml.StrictMissingError
+		decodeErr *toml.DecodeError
+	)
+	switch {
+	case errors.As(err, &strictErr):
+		for _, e := range strictErr.Errors {
+			line, column := e.Position()
+			problems = append(problems, Problem{line, column, fmt.Sprintf("unknown option %s", strings.Join(e.Key(), "."))})
+		}
+	case errors.As(err, &decodeErr):
+		line, column := decodeErr.Position()
+		msg := strings.TrimPrefix(decodeErr.Error(), "toml: ")
+		if m := typeErrRegexp.FindStringSubmatch(msg); m != nil {
+			msg = fmt.Sprintf("option must be %s, got %s", withArticle(m[2]), m[1])
+			for key, pos := range keyPositions(data) {
+				if pos.Line == line {
+					msg = fmt.Sprintf("option %s must be %s, got %s", key, withArticle(m[2]), m[1])
+					break
+				}
+			}
+		}
+		return []Problem{{line, column, msg}}
+	case err != nil:
+		return []Problem{{Message: err.Error()}}
+	}
+
+	positions := keyPositions(data)
+	problemAt := func(key string, err error) Problem {
+		pos := positions[key]
+		return Problem{pos.Line, pos.Column, err.Error()}
+	}
+
+	var options map[string]any
+	if err := toml.Unmarshal(data, &options); err != nil {
+		return append(problems, Problem{Message: err.Error()})
+	}
+	for key, value := range options {
+		if key == "match" {
+			continue
+		}
+		if err := checkOption(key, value); err != nil {
+			problems = append(problems, problemAt(key, err))
+		}
+	}
+
+	for i, block := range doc.Match {
+		// each key is set on its own so that its problem is reported at the
+		// key rather than at the [[match]] header
+		prefix := fmt.Sprintf("match.%d", i+1)
+		m := Match{settings: map[string]any{}}
+		hasPredicateKey := false
+		for key, value := range block {
+			switch key {
+			case "path_glob", "remote_regex", "branch_regex":
+				hasPredicateKey = true
+			}
+			err := m.set(key, value)
+			if err == nil {
+				err = checkOption(key, value)
+			}
+			if err != nil {
+				problems = append(problems, problemAt(prefix+"."+key, fmt.Errorf("match %d: %w", i+1, err)))
+			}
+		}
+		if !hasPredicateKey {
+			problems = append(problems, problemAt(prefix, fmt.Errorf("match %d: %w", i+1, errNoPredicate)))
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Line != problems[j].Line {
+			return problems[i].Line < problems[j].Line
+		}
+		return problems[i].Column < problems[j].Column
+	})
+	return problems
+}
+
+// CheckGitConfig returns the problems of the git config variables in
+// GitConfigSection, such as an unknown option, which MergeGitConfig ignores.
+// The problems have no position.
+func CheckGitConfig(vars [][2]string) []Problem {
+	var problems []Problem
+	for _, v := range vars {
+		name, found := strings.CutPrefix(v[0], GitConfigSection+".")
+		if !found {
+			continue
+		}
+		key := strings.ReplaceAll(name, "-", "_")
+		c := GitPromptStringConfig{}
+		var err error
+		if _, ok := c.field(key); !ok {
+			err = fmt.Errorf("git config %s: unknown option %s", v[0], key)
+		} else if err = c.MergeGitConfig([][2]string{v}); err == nil {
+			if err = checkOption(key, v[1]); err != nil {
+				err = fmt.Errorf("git config %s: %w", v[0], err)
+			}
+		}
+		if err != nil {
+			problems = append(problems, Problem{Message: err.Error()})
+		}
+	}
+	return problems
+}
+
+// checkOption validates the colors, verbs, and supported values of a string
+// option with the parsers that are used when the prompt is displayed. Options
+// of other types are validated when the document is decoded.
+func checkOption(key string, value any) error {
+	s, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	var err error
+	switch key {
+	case "shell":
+		err = color.CheckShell(s)
+	case "output":
+		err = color.CheckOutput(s)
+	case "color":
+		// the environment only decides whether auto enables colors
+		_, err = color.Enabled(s, func(string) string { return "" })
+	case "color_depth":
+		if s != "auto" {
+			_, err = color.ParseDepth(s)
+		}
+	case "branch_truncation":
+		err = util.CheckTruncatePosition(s)
+	case "timeout":
+		// an empty timeout waits indefinitely
+		if s != "" {
+			_, err = time.ParseDuration(s)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	if strings.HasPrefix(key, "color_") && key != "color_depth" {
+		if _, err := color.Color(strings.Fields(s)...); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	if expected, ok := verbCounts[key]; ok {
+		if key == "stash_format" && s == "" {
+			// an empty stash format hides the stash count
+			return nil
+		}
+		if n := countVerbs(s); n != expected {
+			verbs := "verbs"
+			if expected == 1 {
+				verbs = "verb"
+			}
+			return fmt.Errorf("%s: expected %d %%v %s, got %d", key, expected, verbs, n)
+		}
+	}
+	return nil
+}
+
+// countVerbs returns the number of verbs in the fmt format string. An escaped
+// percent sign, %%, is not a verb.
+func countVerbs(format string) int {
+	n := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0123456789.[]*", format[i]) >= 0 {
+			i++
+		}
+		if i < len(format) && format[i] != '%' {
+			n++
+		}
+	}
+	return n
+}
+
+// keyPositions returns the position of each key in the TOML document data.
+// The keys of a table are prefixed with the name of the table, and the keys of
+// an array of tables are prefixed with the name and the 1-indexed position of
+// the table, e.g., match.2.color_clean.
+func keyPositions(data []byte) map[string]unstable.Position {
+	positions := map[string]unstable.Position{}
+	arrays := map[string]int{}
+	prefix := ""
+
+	p := unstable.Parser{}
+	p.Reset(data)
+	for p.NextExpression() {
+		expr := p.Expression()
+		switch expr.Kind {
+		case unstable.Table, unstable.ArrayTable, unstable.KeyValue:
+		default:
+			continue
+		}
+
+		var parts []string
+		var start unstable.Position
+		it := expr.Key()
+		for it.Next() {
+			node := it.Node()
+			if len(parts) == 0 {
+				start = p.Shape(node.Raw).Start
+			}
+			parts = append(parts, string(node.Data))
+		}
+		key := strings.Join(parts, ".")
+
+		switch expr.Kind {
+		case unstable.Table:
+			prefix = key + "."
+			positions[key] = start
+		case unstable.ArrayTable:
+			arrays[key]++
+			key = fmt.Sprintf("%s.%d", key, arrays[key])
+			prefix = key + "."
+			positions[key] = start
+		case unstable.KeyValue:
+			positions[prefix+key] = start
+		}
+	}
+	return positions
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		doc      string
+		expected []Problem
+	}{
+		{"color_clean = 'bold green'\nahead_format = '+%v'\n", nil},
+		{"stash_format = ''\ndiverged_format = '%%%v %-3v'\n", nil},
+		{"prompt_prefix = 'a'\nprompt_sufix = 'b'\n", []Problem{{2, 1, "unknown option prompt_sufix"}}},
+		{"format '%v'\n", []Problem{{1, 8, "expected character ="}}},
+		{"count_changes = 'yes'\n", []Problem{{1, 17, "option count_changes must be a bool, got TOML string"}}},
+		{"branch_max_length = 'long'\n", []Problem{{1, 21, "option branch_max_length must be an int, got TOML string"}}},
+		{"color_clean = 'bold green'\n  color_dirty = 'fg:nope'\n", []Problem{{2, 3, "color_dirty: color fg:nope not found"}}},
+		{"behind_format = '%v %v'\nno_upstream_remote_format = '%v'\n", []Problem{
+			{1, 1, "behind_format: expected 1 %v verb, got 2"},
+			{2, 1, "no_upstream_remote_format: expected 2 %v verbs, got 1"},
+		}},
+		{"shell = 'zsh'\noutput = 'tmux'\ncolor = 'auto'\ncolor_depth = 'auto'\nbranch_truncation = 'middle'\ntimeout = ''\n", nil},
+		{"shell = 'ksh'\noutput = 'html'\n  color = 'sometimes'\ncolor_depth = '8'\nbranch_truncation = 'left'\ntimeout = 'soon'\n", []Problem{
+			{1, 1, "shell: shell ksh not supported"},
+			{2, 1, "output: output html not supported, expected one of prompt, tmux, env"},
+			{3, 3, "color: color sometimes not supported, expected one of auto, always, or never"},
+			{4, 1, "color_depth: color depth 8 not supported"},
+			{5, 1, "branch_truncation: truncation position left not supported, expected one of start, middle, end"},
+			{6, 1, `timeout: time: invalid duration "soon"`},
+		}},
+		{"[[match]]\nbranch_regex = 'a'\n\n[[match]]\ncolor_dirty = 'red'\n\n[[match]]\nbranch_regex = 'b'\ncolor_dirty = '300'\n", []Problem{
+			{4, 3, "match 2: one of path_glob, remote_regex, or branch_regex is required"},
+			{9, 1, "match 3: color_dirty: color index must be between 0 and 255, got 300"},
+		}},
+		{"[[match]]\nbranch_regex = 'a'\n  colour_dirty = 'red'\ncount_changes = 'yes'\n\n[[match]]\nbranch_regex = '('\n", []Problem{
+			{3, 3, "match 1: unknown option colour_dirty"},
+			{4, 1, "match 1: option count_changes must be a bool, got string"},
+			{7, 1, "match 2: branch_regex: error parsing regexp: missing closing ): `(`"},
+		}},
+	}
+
+	for _, test := range tests {
+		actual := Check([]byte(test.doc))
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.doc, test.expected, actual)
+		}
+	}
+}
+
+func TestCheckGitConfig(t *testing.T) {
+	actual := CheckGitConfig([][2]string{
+		{"prompt-string.color-clean", "cyan"},
+		{"prompt-string.colour", "red"},
+		{"prompt-string.count-changes", "maybe"},
+		{"prompt-string.ahead-format", "%v %v"},
+		{"prompt-string.timeout", "1 second"},
+		{"other.colour", "red"},
+	})
+	expected := []Problem{
+		{Message: "git config prompt-string.colour: unknown option colour"},
+		{Message: `git config prompt-string.count-changes: option count_changes: strconv.ParseBool: parsing "maybe": invalid syntax`},
+		{Message: "git config prompt-string.ahead-format: ahead_format: expected 1 %v verb, got 2"},
+		{Message: `git config prompt-string.timeout: timeout: time: unknown unit " second" in duration "1 second"`},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestCountVerbs(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected int
+	}{
+		{"", 0},
+		{"%v", 1},
+		{"%%v", 0},
+		{"↕ ↑[%v] ↓[%v]", 2},
+		{"%-5d %+.2f %[1]v", 3},
+		{"100%", 0},
+	}
+
+	for _, test := range tests {
+		if actual := countVerbs(test.format); actual != test.expected {
+			t.Errorf("%q: expected %d, got %d", test.format, test.expected, actual)
+		}
+	}
+}
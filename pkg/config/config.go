@@ -8,10 +8,48 @@ type GitPromptStringConfig struct {
 	DivergedFormat         string `toml:"diverged_format"`
 	NoUpstreamRemoteFormat string `toml:"no_upstream_remote_format"`
 	ColorDisabled          bool   `toml:"color_disabled"`
+	Color                  string `toml:"color"`
 	ColorClean             string `toml:"color_clean"`
 	ColorDelta             string `toml:"color_delta"`
 	ColorDirty             string `toml:"color_dirty"`
 	ColorUntracked         string `toml:"color_untracked"`
 	ColorNoUpstream        string `toml:"color_no_upstream"`
 	ColorMerging           string `toml:"color_merging"`
+	ColorPrefix            string `toml:"color_prefix"`
+	ColorBranch            string `toml:"color_branch"`
+	ColorMergeState        string `toml:"color_merge_state"`
+	ColorSparse            string `toml:"color_sparse"`
+	ColorAheadBehind       string `toml:"color_ahead_behind"`
+	ColorDirtyMarker       string `toml:"color_dirty_marker"`
+	ColorSuffix            string `toml:"color_suffix"`
+	Format                 string `toml:"format"`
+	CountChanges           bool   `toml:"count_changes"`
+	StagedFormat           string `toml:"staged_format"`
+	ModifiedFormat         string `toml:"modified_format"`
+	DeletedFormat          string `toml:"deleted_format"`
+	RenamedFormat          string `toml:"renamed_format"`
+	UntrackedFormat        string `toml:"untracked_format"`
+	ConflictedFormat       string `toml:"conflicted_format"`
+	StashFormat            string `toml:"stash_format"`
+	ColorStash             string `toml:"color_stash"`
+	Timeout                string `toml:"timeout"`
+	TimeoutMarker          string `toml:"timeout_marker"`
+	ColorTimeout           string `toml:"color_timeout"`
+	Shell                  string `toml:"shell"`
+	ColorDepth             string `toml:"color_depth"`
+	Output                 string `toml:"output"`
+	Powerline              bool   `toml:"powerline"`
+	PowerlineSeparator     string `toml:"powerline_separator"`
+	PowerlineThinSeparator string `toml:"powerline_thin_separator"`
+	BranchMaxLength        int    `toml:"branch_max_length"`
+	BranchTruncation       string `toml:"branch_truncation"`
+	BranchEllipsis         string `toml:"branch_ellipsis"`
+	BranchAbbreviate       bool   `toml:"branch_abbreviate"`
+
+	// Matches are the [[match]] blocks of the merged configuration files.
+	Matches []Match `toml:"-"`
+	// Sources are the layers that last set each option, keyed by the toml key,
+	// e.g., file ~/.config/git-prompt-string/config.toml. An option that is
+	// not in Sources has its default value.
+	Sources map[string]string `toml:"-"`
 }
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/mikesmithgh/git-prompt-string/pkg/util"
+)
+
+// Match is a [[match]] block of the configuration. Its settings override the
+// options of the configuration when every predicate that is set matches the
+// repository.
+type Match struct {
+	PathGlob    string // matches the top-level directory of the repository
+	RemoteRegex string // matches the URL of any remote
+	BranchRegex string // matches the current branch
+	path        *regexp.Regexp
+	remote      *regexp.Regexp
+	branch      *regexp.Regexp
+	settings    map[string]any
+	source      string
+}
+
+// Target is the repository that match blocks are tested against. Path and
+// RemoteURLs are only called if a match block has the corresponding predicate.
+type Target struct {
+	Branch     string
+	Path       func() (string, error)
+	RemoteURLs func() ([]string, error)
+}
+
+// errNoPredicate is returned for a [[match]] block without a predicate.
+var errNoPredicate = errors.New("one of path_glob, remote_regex, or branch_regex is required")
+
+func newMatch(block map[string]any) (Match, error) {
+	m := Match{settings: map[string]any{}}
+	for key, value := range block {
+		if err := m.set(key, value); err != nil {
+			return Match{}, err
+		}
+	}
+	if !m.hasPredicate() {
+		return Match{}, errNoPredicate
+	}
+	return m, nil
+}
+
+// set sets the predicate or the setting with the toml key to a value decoded
+// from TOML.
+func (m *Match) set(key string, value any) error {
+	switch key {
+	case "path_glob", "remote_regex", "branch_regex":
+		pattern, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string, got %T", key, value)
+		}
+		var err error
+		switch key {
+		case "path_glob":
+			m.PathGlob = pattern
+			m.path, err = util.GlobRegexp(pattern)
+		case "remote_regex":
+			m.RemoteRegex = pattern
+			m.remote, err = regexp.Compile(pattern)
+		case "branch_regex":
+			m.BranchRegex = pattern
+			m.branch, err = regexp.Compile(pattern)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	default:
+		if err := (&GitPromptStringConfig{}).setValue(key, value); err != nil {
+			return err
+		}
+		m.settings[key] = value
+	}
+	return nil
+}
+
+func (m *Match) hasPredicate() bool {
+	return m.path != nil || m.remote != nil || m.branch != nil
+}
+
+func (m *Match) matches(t *Target) (bool, error) {
+	if m.branch != nil && !m.branch.MatchString(t.Branch) {
+		return false, nil
+	}
+	if m.path != nil {
+		p, err := t.Path()
+		if err != nil {
+			return false, err
+		}
+		if !m.path.MatchString(filepath.ToSlash(p)) {
+			return false, nil
+		}
+	}
+	if m.remote != nil {
+		remotes, err := t.RemoteURLs()
+		if err != nil {
+			return false, err
+		}
+		matched := false
+		for _, url := range remotes {
+			if m.remote.MatchString(url) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ApplyMatches sets the options of every match block that matches t, in the
+// order the blocks were merged.
+func (c *GitPromptStringConfig) ApplyMatches(t Target) error {
+	var (
+		path    string
+		pathErr error
+		hasPath bool
+		urls    []string
+		urlsErr error
+		hasURLs bool
+	)
+	cached := Target{
+		Branch: t.Branch,
+		Path: func() (string, error) {
+			if !hasPath {
+				path, pathErr = t.Path()
+				hasPath = true
+			}
+			return path, pathErr
+		},
+		RemoteURLs: func() ([]string, error) {
+			if !hasURLs {
+				urls, urlsErr = t.RemoteURLs()
+				hasURLs = true
+			}
+			return urls, urlsErr
+		},
+	}
+
+	for _, m := range c.Matches {
+		ok, err := m.matches(&cached)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		for key, value := range m.settings {
+			if err := c.setValue(key, value); err != nil {
+				return err
+			}
+			c.SetSource(key, m.source)
+		}
+	}
+	return nil
+}
+
+// setValue sets the option with the toml key to a value decoded from TOML.
+func (c *GitPromptStringConfig) setValue(key string, value any) error {
+	f, ok := c.field(key)
+	if !ok {
+		return fmt.Errorf("unknown option %s", key)
+	}
+	v := reflect.ValueOf(value)
+	// TOML integers are decoded as int64
+	if v.IsValid() && v.Kind() == reflect.Int64 && f.Kind() == reflect.Int {
+		v = v.Convert(f.Type())
+	}
+	if !v.IsValid() || !v.Type().AssignableTo(f.Type()) {
+		return fmt.Errorf("option %s must be %s, got %T", key, withArticle(f.Kind().String()), value)
+	}
+	f.Set(v)
+	return nil
+}
+
+// withArticle returns the name of a type with its indefinite article, e.g., an
+// int or a bool.
+func withArticle(name string) string {
+	if name != "" && strings.ContainsRune("aeiou", rune(name[0])) {
+		return "an " + name
+	}
+	return "a " + name
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyMatches(t *testing.T) {
+	cfg := GitPromptStringConfig{ColorClean: "green", PromptPrefix: " "}
+	err := cfg.Merge([]byte(`
+[[match]]
+branch_regex = '^release/'
+color_clean = 'red'
+branch_max_length = 20
+
+[[match]]
+remote_regex = 'github\.com[:/]our-org/'
+path_glob = '/work/**'
+prompt_prefix = 'org '
+count_changes = true
+
+[[match]]
+path_glob = '/personal/**'
+prompt_prefix = 'personal '
+`), "file config.toml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	pathCalls := 0
+	target := Target{
+		Branch: "release/1.0",
+		Path: func() (string, error) {
+			pathCalls++
+			return "/work/repo", nil
+		},
+		RemoteURLs: func() ([]string, error) {
+			return []string{"git@github.com:fork/repo.git", "https://github.com/our-org/repo.git"}, nil
+		},
+	}
+	if err := cfg.ApplyMatches(target); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.ColorClean != "red" || cfg.BranchMaxLength != 20 || cfg.PromptPrefix != "org " || !cfg.CountChanges {
+		t.Errorf("expected the first two matches to apply, got %+v", cfg)
+	}
+	if cfg.Sources["color_clean"] != "file config.toml, match 1" || cfg.Sources["prompt_prefix"] != "file config.toml, match 2" {
+		t.Errorf("expected the sources to be the matches, got %v", cfg.Sources)
+	}
+	if pathCalls != 1 {
+		t.Errorf("expected the path to be read once, got %d", pathCalls)
+	}
+
+	target.Path = func() (string, error) { return "", errors.New("no work tree") }
+	if err := cfg.ApplyMatches(target); err == nil || err.Error() != "no work tree" {
+		t.Errorf("expected path error, got %v", err)
+	}
+}
+
+func TestMergeInvalidMatch(t *testing.T) {
+	tests := []struct {
+		doc string
+		err string
+	}{
+		{"[[match]]\ncolor_clean = 'red'", "match 1: one of path_glob, remote_regex, or branch_regex is required"},
+		{"[[match]]\nbranch_regex = 1", "match 1: branch_regex must be a string, got int64"},
+		{"[[match]]\nbranch_regex = 'main'\ncolour_clean = 'red'", "match 1: unknown option colour_clean"},
+		{"[[match]]\nbranch_regex = 'main'\ncount_changes = 'yes'", "match 1: option count_changes must be a bool, got string"},
+		{"[[match]]\nbranch_regex = 'main'\nbranch_max_length = 'long'", "match 1: option branch_max_length must be an int, got string"},
+	}
+
+	for _, test := range tests {
+		cfg := GitPromptStringConfig{}
+		err := cfg.Merge([]byte(test.doc), "file config.toml")
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%q: expected error %q, got %v", test.doc, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// GitConfigSection is the section of the git config variables that set
+// options, e.g., prompt-string.color-dirty sets color_dirty.
+const GitConfigSection = "prompt-string"
+
+// EnvPrefix is the prefix of the environment variables that set options,
+// e.g., GIT_PROMPT_STRING_COLOR_DIRTY sets color_dirty.
+const EnvPrefix = "GIT_PROMPT_STRING_"
+
+// RepoFileName is the name of the configuration file in the git directory of
+// a repository.
+const RepoFileName = "git-prompt-string.toml"
+
+// field returns the field of c with the toml key.
+func (c *GitPromptStringConfig) field(key string) (reflect.Value, bool) {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("toml") == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// Set sets the option with the toml key to value. The value of a boolean
+// option is parsed with strconv.ParseBool and the value of an integer option
+// with strconv.Atoi.
+func (c *GitPromptStringConfig) Set(key string, value string) error {
+	f, ok := c.field(key)
+	if !ok {
+		return fmt.Errorf("unknown option %s", key)
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("option %s: %w", key, err)
+		}
+		f.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("option %s: %w", key, err)
+		}
+		f.SetInt(int64(n))
+	default:
+		return fmt.Errorf("option %s cannot be set from a string", key)
+	}
+	return nil
+}
+
+// SetSource records that the option with the toml key was last set by source.
+func (c *GitPromptStringConfig) SetSource(key string, source string) {
+	if c.Sources == nil {
+		c.Sources = map[string]string{}
+	}
+	c.Sources[key] = source
+}
+
+// Merge sets the options in the TOML document data. Options that are not in
+// data are unchanged, so each document is layered over the previous ones. The
+// [[match]] blocks of data are appended to Matches. Source describes where
+// data was read from, e.g., file /path/to/config.toml.
+func (c *GitPromptStringConfig) Merge(data []byte, source string) error {
+	if err := toml.Unmarshal(data, c); err != nil {
+		return err
+	}
+	var doc map[string]any
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	for key := range doc {
+		if _, ok := c.field(key); ok {
+			c.SetSource(key, source)
+		}
+	}
+	var matches struct {
+		Match []map[string]any `toml:"match"`
+	}
+	if err := toml.Unmarshal(data, &matches); err != nil {
+		return err
+	}
+	for i, block := range matches.Match {
+		m, err := newMatch(block)
+		if err != nil {
+			return fmt.Errorf("match %d: %w", i+1, err)
+		}
+		m.source = fmt.Sprintf("%s, match %d", source, i+1)
+		c.Matches = append(c.Matches, m)
+	}
+	return nil
+}
+
+// MergeGitConfig sets the options of the git config variables in
+// GitConfigSection, in order. Each variable is named after the option with
+// hyphens in place of underscores because git does not allow underscores in
+// variable names. Boolean options accept the same values as git, e.g., yes and
+// off, and a variable without a value sets a boolean option to true. Unknown
+// variables are ignored, like unknown options of a configuration file, and are
+// reported by CheckGitConfig.
+func (c *GitPromptStringConfig) MergeGitConfig(vars [][2]string) error {
+	for _, v := range vars {
+		name, found := strings.CutPrefix(v[0], GitConfigSection+".")
+		if !found {
+			continue
+		}
+		key := strings.ReplaceAll(name, "-", "_")
+		f, ok := c.field(key)
+		if !ok {
+			continue
+		}
+		value := v[1]
+		if f.Kind() == reflect.Bool {
+			switch strings.ToLower(value) {
+			case "", "yes", "on":
+				value = "true"
+			case "no", "off":
+				value = "false"
+			}
+		}
+		if err := c.Set(key, value); err != nil {
+			return fmt.Errorf("git config %s: %w", v[0], err)
+		}
+		c.SetSource(key, "git config "+v[0])
+	}
+	return nil
+}
+
+// MergeEnv sets the options of the environment variables that are named after
+// the option in upper case with EnvPrefix, e.g., GIT_PROMPT_STRING_COLOR_DIRTY.
+// A variable that is set to an empty string sets the option to an empty
+// string. lookupEnv is usually os.LookupEnv.
+func (c *GitPromptStringConfig) MergeEnv(lookupEnv func(string) (string, bool)) error {
+	for _, o := range c.Options() {
+		name := EnvPrefix + strings.ToUpper(o.Key)
+		value, ok := lookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := c.Set(o.Key, value); err != nil {
+			return fmt.Errorf("environment variable %s: %w", name, err)
+		}
+		c.SetSource(o.Key, "env "+name)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	cfg := GitPromptStringConfig{}
+	if err := cfg.Set("color_dirty", "bold red"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := cfg.Set("count_changes", "true"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.ColorDirty != "bold red" || !cfg.CountChanges {
+		t.Errorf("expected color_dirty and count_changes to be set, got %+v", cfg)
+	}
+
+	if err := cfg.Set("colour_dirty", "red"); err == nil || err.Error() != "unknown option colour_dirty" {
+		t.Errorf("expected unknown option error, got %v", err)
+	}
+	if err := cfg.Set("color_disabled", "maybe"); err == nil {
+		t.Errorf("expected error parsing boolean option")
+	}
+
+	if err := cfg.Set("branch_max_length", "20"); err != nil || cfg.BranchMaxLength != 20 {
+		t.Errorf("expected branch_max_length to be set, got %d: %v", cfg.BranchMaxLength, err)
+	}
+	if err := cfg.Set("branch_max_length", "long"); err == nil {
+		t.Errorf("expected error parsing integer option")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	cfg := GitPromptStringConfig{PromptPrefix: "default", ColorClean: "green", ColorDirty: "red"}
+	layers := []struct {
+		doc    string
+		source string
+	}{
+		{"color_clean = 'cyan'\ncolor_dirty = 'magenta'", "file a.toml"},
+		{"color_dirty = 'yellow'", "file b.toml"},
+	}
+	for _, layer := range layers {
+		if err := cfg.Merge([]byte(layer.doc), layer.source); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if cfg.PromptPrefix != "default" || cfg.ColorClean != "cyan" || cfg.ColorDirty != "yellow" {
+		t.Errorf("expected each layer to override the previous options, got %+v", cfg)
+	}
+	expected := map[string]string{"color_clean": "file a.toml", "color_dirty": "file b.toml"}
+	if !reflect.DeepEqual(cfg.Sources, expected) {
+		t.Errorf("expected sources %v, got %v", expected, cfg.Sources)
+	}
+}
+
+func TestMergeGitConfig(t *testing.T) {
+	cfg := GitPromptStringConfig{ColorClean: "green"}
+	err := cfg.MergeGitConfig([][2]string{
+		{"prompt-string.color-dirty", "red"},
+		{"prompt-string.color-dirty", "magenta"},
+		{"prompt-string.count-changes", ""},
+		{"prompt-string.color-disabled", "on"},
+		{"other.color-clean", "cyan"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.ColorDirty != "magenta" || cfg.ColorClean != "green" || !cfg.CountChanges || !cfg.ColorDisabled {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if source := cfg.Sources["color_dirty"]; source != "git config prompt-string.color-dirty" {
+		t.Errorf("expected the source of color_dirty to be git config, got %s", source)
+	}
+
+	err = cfg.MergeGitConfig([][2]string{{"prompt-string.count-changes", "off"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.CountChanges {
+		t.Errorf("expected count_changes to be false")
+	}
+
+	err = cfg.MergeGitConfig([][2]string{{"prompt-string.colour", "red"}, {"prompt-string.color-clean", "cyan"}})
+	if err != nil {
+		t.Fatalf("expected unknown options to be ignored, got %s", err)
+	}
+	if cfg.ColorClean != "cyan" {
+		t.Errorf("expected color_clean to be cyan, got %s", cfg.ColorClean)
+	}
+}
+
+func TestMergeEnv(t *testing.T) {
+	env := map[string]string{
+		"GIT_PROMPT_STRING_COLOR_DIRTY":     "bold red",
+		"GIT_PROMPT_STRING_COUNT_CHANGES":   "true",
+		"GIT_PROMPT_STRING_PROMPT_SUFFIX":   "",
+		"GIT_PROMPT_STRING_CONFIG":          "NONE",
+		"GIT_PROMPT_STRING_color_untracked": "blue",
+	}
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	cfg := GitPromptStringConfig{ColorClean: "green", ColorUntracked: "magenta", PromptSuffix: " end"}
+	if err := cfg.MergeEnv(lookupEnv); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.ColorDirty != "bold red" || !cfg.CountChanges || cfg.PromptSuffix != "" {
+		t.Errorf("expected the environment variables to be merged, got %+v", cfg)
+	}
+	if cfg.ColorClean != "green" || cfg.ColorUntracked != "magenta" {
+		t.Errorf("expected the options without environment variables to be unchanged, got %+v", cfg)
+	}
+	if source := cfg.Sources["color_dirty"]; source != "env GIT_PROMPT_STRING_COLOR_DIRTY" {
+		t.Errorf("expected the source of color_dirty to be env, got %s", source)
+	}
+
+	env["GIT_PROMPT_STRING_COLOR_DISABLED"] = "maybe"
+	err := cfg.MergeEnv(lookupEnv)
+	expected := `environment variable GIT_PROMPT_STRING_COLOR_DISABLED: option color_disabled: strconv.ParseBool: parsing "maybe": invalid syntax`
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Option is an option of the configuration and its value.
+type Option struct {
+	Key   string
+	Value any
+}
+
+// Options returns the options of c in the order they are declared.
+func (c *GitPromptStringConfig) Options() []Option {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	var options []Option
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("toml")
+		if key == "" || key == "-" {
+			continue
+		}
+		options = append(options, Option{key, v.Field(i).Interface()})
+	}
+	return options
+}
+
+// TOML returns the option as a TOML key/value pair, e.g., color_clean =
+// 'green'.
+func (o Option) TOML() (string, error) {
+	b, err := toml.Marshal(map[string]any{o.Key: o.Value})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
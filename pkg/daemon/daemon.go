@@ -0,0 +1,660 @@
+// Package daemon caches the state of git repositories in a long-running
+// process so that prompts are rendered without running git. The daemon
+// watches the directories of the working tree that are not ignored and the
+// files of the git directory that affect the prompt, and forgets the cached
+// state as soon as any of them changes.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikesmithgh/git-prompt-string/pkg/git"
+)
+
+// Request asks the daemon for the state of the repository of Dir. Version is
+// the version of the client, which must match the version of the daemon.
+type Request struct {
+	Version string
+	Dir     string
+}
+
+// Response is the state of the repository of a Request. Repo has no git
+// directory if the directory of the Request is not in a repository.
+type Response struct {
+	Repo  *git.GitRepo `json:",omitempty"`
+	Error string       `json:",omitempty"`
+}
+
+// SocketPath returns the path of the Unix socket of the daemon in the
+// XDG_RUNTIME_DIR directory.
+func SocketPath() (string, error) {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		return "", errors.New("XDG_RUNTIME_DIR is not set")
+	}
+	return filepath.Join(runtimeDir, "git-prompt-string", "daemon.sock"), nil
+}
+
+// Query asks the daemon listening on socketPath for the state of the
+// repository of dir. The returned repository has been collected and must
+// not be collected again.
+func Query(ctx context.Context, socketPath string, version string, dir string) (*git.GitRepo, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := json.NewEncoder(conn).Encode(Request{Version: version, Dir: dir}); err != nil {
+		return nil, err
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	if resp.Repo == nil {
+		return nil, errors.New("daemon returned no repository")
+	}
+	return resp.Repo, nil
+}
+
+// ListenAndServe listens on the Unix socket at socketPath and serves requests
+// until ctx is done or the server has been idle for its IdleTimeout. The
+// socket is removed when the server stops.
+func ListenAndServe(ctx context.Context, socketPath string, s *Server) error {
+	err := os.MkdirAll(filepath.Dir(socketPath), 0o700)
+	if err != nil {
+		return err
+	}
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		conn.Close()
+		return fmt.Errorf("a daemon is already listening on %s", socketPath)
+	}
+	// the socket of a daemon that did not stop cleanly is left behind
+	err = os.Remove(socketPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socketPath)
+	return s.Serve(ctx, ln)
+}
+
+// Server answers the requests of clients from a cache of the state of each
+// repository.
+type Server struct {
+	// Version is the version of the clients that are served.
+	Version string
+	// IdleTimeout is how long the server waits for a request before it
+	// stops.
+	IdleTimeout time.Duration
+	// EvictTimeout is how long the state of a repository is kept without a
+	// request for it. The repository is no longer watched once evicted.
+	EvictTimeout time.Duration
+	// ErrorLog logs the repositories that cannot be watched, e.g., because
+	// the limit of inotify watches is reached. If nil, the standard logger
+	// of the log package is used.
+	ErrorLog *log.Logger
+
+	mu          sync.Mutex
+	watcher     watcher
+	repos       map[repoKey]*repo
+	dirs        map[string]*repo
+	wds         map[int][]*repo
+	paths       map[int]string
+	calls       map[string]*call
+	lastRequest time.Time
+	isIdle      bool
+}
+
+// repoKey identifies a watched repository. workTree is empty if the requests
+// were made from the git directory or a bare repository.
+type repoKey struct {
+	gitDir   string
+	workTree string
+}
+
+// repo is a watched repository and the state collected in each requested
+// directory. generation is incremented whenever the repository changes so
+// that state collected during a change is not cached.
+type repo struct {
+	key       repoKey
+	commonDir string
+	// gitDirs are the directories of the git directory and the common git
+	// directory whose files affect the prompt. The refs are watched too.
+	gitDirs map[string]bool
+	// ignored are the ignored directories of the working tree, which are
+	// not watched.
+	ignored    map[string]bool
+	watches    map[int]bool
+	results    map[string]*git.GitRepo
+	generation int
+	lastUsed   time.Time
+	// isUnwatched is set if the repository could not be watched, e.g., the
+	// limit of inotify watches was reached, in which case nothing is cached.
+	isUnwatched bool
+}
+
+// gitDirWatches and commonDirWatches are the directories of the git directory
+// and the common git directory whose files affect the prompt, e.g., HEAD, the
+// index, the state of a rebase, packed-refs, the configuration, info/exclude,
+// and the reflog of the stash.
+var (
+	gitDirWatches    = []string{"", "rebase-merge", "rebase-apply"}
+	commonDirWatches = []string{"", "info", "logs", filepath.Join("logs", "refs")}
+)
+
+// newRepo returns the repository with key and the git directory of g, whose
+// ignored directories are relative to the top-level directory of the
+// working tree.
+func newRepo(key repoKey, g *git.GitRepo, ignored []string) *repo {
+	r := &repo{
+		key:       key,
+		commonDir: g.CommonDirPath(""),
+		gitDirs:   map[string]bool{},
+		watches:   map[int]bool{},
+		results:   map[string]*git.GitRepo{},
+		lastUsed:  time.Now(),
+	}
+	for _, dir := range gitDirWatches {
+		r.gitDirs[filepath.Join(key.gitDir, dir)] = true
+	}
+	for _, dir := range commonDirWatches {
+		r.gitDirs[filepath.Join(r.commonDir, dir)] = true
+	}
+	r.setIgnored(ignored)
+	return r
+}
+
+func (r *repo) setIgnored(ignored []string) {
+	r.ignored = map[string]bool{}
+	for _, dir := range ignored {
+		r.ignored[filepath.Join(r.key.workTree, filepath.FromSlash(dir))] = true
+	}
+}
+
+// name returns the working tree of r, or its git directory if r has no
+// working tree.
+func (r *repo) name() string {
+	if r.key.workTree != "" {
+		return r.key.workTree
+	}
+	return r.key.gitDir
+}
+
+// affects reports whether the files of the directory at path affect the state
+// of r, i.e., path is a directory of gitDirs, the refs or a directory below
+// them, or a directory of the working tree that is not ignored.
+func (r *repo) affects(path string) bool {
+	switch {
+	case r.gitDirs[path], isWithin(path, filepath.Join(r.commonDir, "refs")):
+		return true
+	case isWithin(path, r.key.gitDir), isWithin(path, r.commonDir):
+		return false
+	case !r.inWorkTree(path):
+		return false
+	}
+	return filepath.Base(path) != ".git" && !r.ignored[path]
+}
+
+// inWorkTree reports whether path is in the working tree of r and not in its
+// git directory.
+func (r *repo) inWorkTree(path string) bool {
+	return r.key.workTree != "" && isWithin(path, r.key.workTree) && !isWithin(path, r.key.gitDir)
+}
+
+// isWithin reports whether path is dir or below it.
+func isWithin(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// call is the collection of the state of a directory, which concurrent
+// requests for the directory wait for.
+type call struct {
+	done chan struct{}
+	repo *git.GitRepo
+	err  error
+}
+
+// Serve accepts connections on ln until ctx is done or the server has been
+// idle for its IdleTimeout. Serve closes ln.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	if s.IdleTimeout <= 0 || s.EvictTimeout <= 0 {
+		ln.Close()
+		return errors.New("the idle and evict timeouts must be positive")
+	}
+	w, err := newWatcher()
+	if err != nil {
+		ln.Close()
+		return err
+	}
+	s.mu.Lock()
+	s.watcher = w
+	s.repos = map[repoKey]*repo{}
+	s.dirs = map[string]*repo{}
+	s.wds = map[int][]*repo{}
+	s.paths = map[int]string{}
+	s.calls = map[string]*call{}
+	s.lastRequest = time.Now()
+	s.mu.Unlock()
+	defer w.close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.watch()
+	go s.janitor(ln, done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			ln.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			isIdle := s.isIdle
+			s.mu.Unlock()
+			if isIdle || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle answers the request of a single connection.
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+
+	var resp Response
+	switch {
+	case req.Version != s.Version:
+		resp.Error = fmt.Sprintf("daemon version %s does not match client version %s", s.Version, req.Version)
+	case !filepath.IsAbs(req.Dir):
+		resp.Error = fmt.Sprintf("directory %s is not absolute", req.Dir)
+	default:
+		g, err := s.get(req.Dir)
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		resp.Repo = g
+	}
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+// get returns the state of the repository of dir from the cache, or collects
+// it if it is not cached.
+func (s *Server) get(dir string) (*git.GitRepo, error) {
+	s.mu.Lock()
+	s.lastRequest = time.Now()
+	if r := s.dirs[dir]; r != nil {
+		r.lastUsed = s.lastRequest
+		if g, ok := r.results[dir]; ok {
+			s.mu.Unlock()
+			return g, nil
+		}
+	}
+	c, ok := s.calls[dir]
+	if !ok {
+		c = &call{done: make(chan struct{})}
+		s.calls[dir] = c
+		go s.collect(dir, c)
+	}
+	s.mu.Unlock()
+
+	<-c.done
+	return c.repo, c.err
+}
+
+// collect collects the state of the repository of dir and caches it unless
+// the repository changed in the meantime.
+func (s *Server) collect(dir string, c *call) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.calls, dir)
+		s.mu.Unlock()
+		close(c.done)
+	}()
+
+	ctx := git.WithDir(context.Background(), dir)
+	g, err := revParse(ctx)
+	if err != nil || g.IsInGitDir == nil {
+		c.repo, c.err = g, err
+		return
+	}
+
+	key := repoKey{gitDir: g.GitDir}
+	if g.IsInWorkTree && !g.IsInBareRepo && !*g.IsInGitDir {
+		key.workTree, err = git.ShowToplevel(ctx)
+		if err != nil {
+			c.err = err
+			return
+		}
+	}
+	var ignored []string
+	if key.workTree != "" && !s.isWatched(key) {
+		// git is run before the lock is held
+		ignored, err = git.IgnoredDirs(git.WithDir(ctx, key.workTree))
+		if err != nil {
+			c.err = err
+			return
+		}
+	}
+	r, generation, isNew := s.watchRepo(key, g, dir, ignored)
+	if isNew {
+		// the repository may have changed before it was watched
+		if g, err = revParse(ctx); err != nil || g.IsInGitDir == nil {
+			c.repo, c.err = g, err
+			return
+		}
+	}
+
+	if err := g.Collect(ctx); err != nil {
+		c.err = err
+		return
+	}
+	c.repo = g
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.generation == generation && !r.isUnwatched && s.repos[key] == r {
+		r.results[dir] = g
+	}
+}
+
+// revParse runs git rev-parse like the client does when there is no daemon.
+// A missing upstream is not an error.
+func revParse(ctx context.Context) (*git.GitRepo, error) {
+	g, _, err := git.RevParse(ctx)
+	switch {
+	case g == nil:
+		return nil, err
+	case errors.Is(err, exec.ErrNotFound):
+		return nil, err
+	}
+	return g, nil
+}
+
+// isWatched reports whether the repository with key is watched.
+func (s *Server) isWatched(key repoKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.repos[key]
+	return ok
+}
+
+// watchRepo starts watching the repository with key if it is not watched and
+// returns it with its current generation. isNew reports whether the
+// repository was not watched before. ignored are the ignored directories of
+// the working tree, relative to its top-level directory.
+func (s *Server) watchRepo(key repoKey, g *git.GitRepo, dir string, ignored []string) (*repo, int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.repos[key]
+	if ok {
+		s.dirs[dir] = r
+		return r, r.generation, false
+	}
+
+	r = newRepo(key, g, ignored)
+	s.repos[key] = r
+	s.dirs[dir] = r
+	s.addRepoWatches(r)
+	return r, r.generation, true
+}
+
+// addRepoWatches watches the directories of r, or stops watching r if they
+// cannot all be watched.
+func (s *Server) addRepoWatches(r *repo) {
+	for _, root := range []string{r.key.workTree, r.key.gitDir, r.commonDir} {
+		if root == "" {
+			continue
+		}
+		if err := s.addWatches(r, root); err != nil {
+			s.stopWatching(r, err)
+			return
+		}
+	}
+}
+
+// stopWatching stops watching r because err occurred while adding a watch, so
+// that the state of r is no longer cached.
+func (s *Server) stopWatching(r *repo, err error) {
+	s.logf("not caching the state of %s: %s", r.name(), err)
+	s.unwatch(r)
+	r.isUnwatched = true
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// addWatches watches the directory root and every directory below it that
+// affects the state of r.
+func (s *Server) addWatches(r *repo, root string) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil // the directory was removed while walking
+		}
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if !r.affects(path) {
+			return filepath.SkipDir
+		}
+		wd, err := s.watcher.add(path)
+		if err != nil {
+			return err
+		}
+		s.paths[wd] = path
+		if !r.watches[wd] {
+			r.watches[wd] = true
+			s.wds[wd] = append(s.wds[wd], r)
+		}
+		return nil
+	})
+}
+
+// watchCreated watches the directory at path that was created in r, unless it
+// is ignored.
+func (s *Server) watchCreated(r *repo, path string) {
+	isIgnored := false
+	if r.inWorkTree(path) {
+		// git is run before the lock is held
+		isIgnored, _ = git.IsIgnored(git.WithDir(context.Background(), r.key.workTree), path)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.repos[r.key] != r || r.isUnwatched || isIgnored {
+		return
+	}
+	if err := s.addWatches(r, path); err != nil {
+		s.stopWatching(r, err)
+		return
+	}
+	// files may have been created before the directory was watched
+	r.invalidate()
+}
+
+// rewatch watches the directories of the working tree of r again, since the
+// ignored directories may have changed.
+func (s *Server) rewatch(r *repo) {
+	// git is run before the lock is held
+	ignored, err := git.IgnoredDirs(git.WithDir(context.Background(), r.key.workTree))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.repos[r.key] != r || r.isUnwatched {
+		return
+	}
+	if err != nil {
+		s.stopWatching(r, err)
+		return
+	}
+	s.unwatch(r)
+	r.setIgnored(ignored)
+	s.addRepoWatches(r)
+	r.invalidate()
+}
+
+// unwatch stops watching r. A directory that is shared with another
+// repository, e.g., the git directory of a work tree and the same git
+// directory requested from within, is still watched for the other
+// repository.
+func (s *Server) unwatch(r *repo) {
+	for wd := range r.watches {
+		repos := s.wds[wd]
+		for i, other := range repos {
+			if other == r {
+				repos = append(repos[:i], repos[i+1:]...)
+				break
+			}
+		}
+		if len(repos) > 0 {
+			s.wds[wd] = repos
+			continue
+		}
+		delete(s.wds, wd)
+		delete(s.paths, wd)
+		_ = s.watcher.remove(wd)
+	}
+	r.watches = map[int]bool{}
+}
+
+// evict forgets r and stops watching it.
+func (s *Server) evict(r *repo) {
+	s.unwatch(r)
+	delete(s.repos, r.key)
+	for dir, other := range s.dirs {
+		if other == r {
+			delete(s.dirs, dir)
+		}
+	}
+}
+
+// watch invalidates the cached state of the repositories whose files change
+// until the watcher is closed.
+func (s *Server) watch() {
+	type created struct {
+		r    *repo
+		path string
+	}
+	for ev := range s.watcher.events() {
+		var (
+			dirs    []created
+			rewatch []*repo
+		)
+		s.mu.Lock()
+		switch {
+		case ev.overflow:
+			// events were dropped, so any repository may have changed
+			for _, r := range s.repos {
+				r.invalidate()
+			}
+		case ev.ignored:
+			// the directory was removed or is no longer watched
+			for _, r := range s.wds[ev.wd] {
+				delete(r.watches, ev.wd)
+				r.invalidate()
+			}
+			delete(s.wds, ev.wd)
+			delete(s.paths, ev.wd)
+		default:
+			path := filepath.Join(s.paths[ev.wd], ev.name)
+			for _, r := range s.wds[ev.wd] {
+				r.invalidate()
+				isExclude := path == filepath.Join(r.commonDir, "info", "exclude") ||
+					(ev.name == ".gitignore" && r.inWorkTree(path))
+				switch {
+				case r.isUnwatched:
+				case isExclude && r.key.workTree != "":
+					rewatch = append(rewatch, r)
+				case ev.isDir && ev.created && r.affects(path):
+					dirs = append(dirs, created{r, path})
+				}
+			}
+		}
+		s.mu.Unlock()
+
+		for _, dir := range dirs {
+			s.watchCreated(dir.r, dir.path)
+		}
+		for _, r := range rewatch {
+			s.rewatch(r)
+		}
+	}
+}
+
+// invalidate forgets the cached state of r.
+func (r *repo) invalidate() {
+	r.generation++
+	clear(r.results)
+}
+
+// janitor evicts the repositories that have not been requested for
+// EvictTimeout and closes ln once the server has been idle for IdleTimeout.
+func (s *Server) janitor(ln net.Listener, done <-chan struct{}) {
+	ticker := time.NewTicker(min(s.IdleTimeout, s.EvictTimeout) / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for _, r := range s.repos {
+				if now.Sub(r.lastUsed) > s.EvictTimeout {
+					s.evict(r)
+				}
+			}
+			isIdle := now.Sub(s.lastRequest) > s.IdleTimeout && len(s.calls) == 0
+			s.isIdle = isIdle
+			s.mu.Unlock()
+			if isIdle {
+				ln.Close()
+				return
+			}
+		}
+	}
+}
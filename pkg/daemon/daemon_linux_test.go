@@ -0,0 +1,154 @@
+package daemon
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEvict(t *testing.T) {
+	dir := t.TempDir()
+	out, err := exec.Command("git", "init", "--quiet", dir).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git init: %s: %s", err, out)
+	}
+
+	socketPath := filepath.Join(t.TempDir(), "daemon.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Version: "test", IdleTimeout: time.Hour, EvictTimeout: 100 * time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() { served <- s.Serve(ctx, ln) }()
+	defer func() {
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}()
+
+	g, err := Query(context.Background(), socketPath, "test", dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if g.IsInGitDir == nil || g.Branch == "" {
+		t.Errorf("expected the state of the repository, got %+v", g)
+	}
+
+	watched := func() (int, int) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.repos), len(s.wds)
+	}
+	if repos, wds := watched(); repos != 1 || wds == 0 {
+		t.Errorf("expected the repository to be watched, got %d repositories and %d watches", repos, wds)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+		if repos, wds := watched(); repos == 0 && wds == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the repository to be evicted")
+		}
+	}
+
+	if _, err := Query(context.Background(), socketPath, "other", dir); err == nil {
+		t.Errorf("expected an error for a client of another version")
+	}
+}
+
+func TestWatchIgnored(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{{"init", "--quiet"}, {"config", "user.name", "test"}, {"config", "user.email", "test@example.com"}, {"commit", "--quiet", "--allow-empty", "--message=initial"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s: %s", args[0], err, out)
+		}
+	}
+	for _, d := range []string{"src", "build/sub"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("build/\n*.tmp/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	socketPath := filepath.Join(t.TempDir(), "daemon.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Version: "test", IdleTimeout: time.Hour, EvictTimeout: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() { served <- s.Serve(ctx, ln) }()
+	defer func() {
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}()
+	if _, err := Query(context.Background(), socketPath, "test", dir); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	isWatched := func(path string) bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, p := range s.paths {
+			if p == filepath.Join(dir, path) {
+				return true
+			}
+		}
+		return false
+	}
+	eventually := func(path string, expected bool) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); isWatched(path) != expected; time.Sleep(20 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected watched to be %t for %s", expected, path)
+			}
+		}
+	}
+
+	for path, expected := range map[string]bool{
+		"":                     true,
+		"src":                  true,
+		".git":                 true,
+		".git/refs/heads":      true,
+		".git/logs/refs":       true,
+		".git/logs/refs/heads": false,
+		"build":                false,
+		"build/sub":            false,
+		".git/objects":         false,
+		".git/hooks":           false,
+	} {
+		if isWatched(path) != expected {
+			t.Errorf("expected watched to be %t for %s", expected, path)
+		}
+	}
+
+	for _, d := range []string{"new", "cache.tmp"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eventually("new", true)
+	if isWatched("cache.tmp") {
+		t.Errorf("expected the ignored directory cache.tmp not to be watched")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("build/\n*.tmp/\nnew/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	eventually("new", false)
+	eventually("src", true)
+}
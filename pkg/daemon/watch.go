@@ -0,0 +1,36 @@
+package daemon
+
+import "errors"
+
+// errWatchLimit is returned by watcher.add when the limit of watches of the
+// user is reached.
+var errWatchLimit = errors.New("the limit of inotify watches is reached, see fs.inotify.max_user_watches")
+
+// event is a change to a watched directory. name is the name of the file in
+// the directory that changed, and isDir and created report whether the file
+// is a directory and whether it was created or moved into the directory.
+// overflow is set if events were dropped and ignored is set once the
+// directory is no longer watched, e.g., because it was removed.
+type event struct {
+	wd       int
+	name     string
+	isDir    bool
+	created  bool
+	overflow bool
+	ignored  bool
+}
+
+// watcher reports the changes to the files of watched directories, but not
+// of their subdirectories.
+type watcher interface {
+	// add watches the directory at path and returns its watch descriptor.
+	// Adding a directory that is already watched returns the same
+	// descriptor.
+	add(path string) (int, error)
+	// remove stops watching the directory with the watch descriptor wd.
+	remove(wd int) error
+	// events returns the channel of events, which is closed once the
+	// watcher is closed.
+	events() <-chan event
+	close() error
+}
@@ -0,0 +1,89 @@
+//go:build linux
+
+package daemon
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// inotifyMask is the set of inotify events that change the state of a
+// repository.
+const inotifyMask = syscall.IN_ATTRIB | syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_DELETE_SELF |
+	syscall.IN_MODIFY | syscall.IN_MOVE_SELF | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DONT_FOLLOW | syscall.IN_EXCL_UNLINK | syscall.IN_ONLYDIR
+
+// inotify is a watcher that uses the inotify API of Linux.
+type inotify struct {
+	fd int
+	// f reads the events of fd with the runtime poller so that closing f
+	// stops a pending read.
+	f  *os.File
+	ch chan event
+}
+
+func newWatcher() (watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	w := &inotify{fd: fd, f: os.NewFile(uintptr(fd), "inotify"), ch: make(chan event, 64)}
+	go w.read()
+	return w, nil
+}
+
+func (w *inotify) add(path string) (int, error) {
+	wd, err := syscall.InotifyAddWatch(w.fd, path, inotifyMask)
+	if errors.Is(err, syscall.ENOSPC) {
+		return 0, &os.PathError{Op: "inotify_add_watch", Path: path, Err: errWatchLimit}
+	}
+	if err != nil {
+		return 0, &os.PathError{Op: "inotify_add_watch", Path: path, Err: err}
+	}
+	return wd, nil
+}
+
+func (w *inotify) remove(wd int) error {
+	_, err := syscall.InotifyRmWatch(w.fd, uint32(wd))
+	return os.NewSyscallError("inotify_rm_watch", err)
+}
+
+func (w *inotify) events() <-chan event {
+	return w.ch
+}
+
+func (w *inotify) close() error {
+	return w.f.Close()
+}
+
+// read sends the events read from the inotify file descriptor until it is
+// closed. Each event is a struct inotify_event followed by the name of the
+// file padded with null bytes.
+func (w *inotify) read() {
+	defer close(w.ch)
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := buf[offset:]
+			mask := binary.NativeEndian.Uint32(raw[4:])
+			nameLen := int(binary.NativeEndian.Uint32(raw[12:]))
+			name := raw[syscall.SizeofInotifyEvent : syscall.SizeofInotifyEvent+nameLen]
+			w.ch <- event{
+				wd:       int(int32(binary.NativeEndian.Uint32(raw))),
+				name:     strings.TrimRight(string(name), "\x00"),
+				isDir:    mask&syscall.IN_ISDIR != 0,
+				created:  mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0,
+				overflow: mask&syscall.IN_Q_OVERFLOW != 0,
+				ignored:  mask&syscall.IN_IGNORED != 0,
+			}
+			offset += syscall.SizeofInotifyEvent + nameLen
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package daemon
+
+import "errors"
+
+// newWatcher returns an error since watching directories requires inotify.
+func newWatcher() (watcher, error) {
+	return nil, errors.New("the daemon is only supported on linux")
+}
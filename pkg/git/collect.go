@@ -0,0 +1,318 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ReadHead reads the current branch and the state of an in-progress
+// operation, e.g., a merge or rebase, from the git directory. ReadHead must be
+// called after RevParse and is called by Collect if it has not been called.
+func (g *GitRepo) ReadHead(ctx context.Context) error {
+	if g.hasReadHead {
+		return nil
+	}
+	g.readMergeState()
+
+	if g.HeadRef == "" {
+		if g.IsGitDirSymlink("HEAD") {
+			ref, err := g.symbolicHead(ctx)
+			if err != nil {
+				return err
+			}
+			g.HeadRef = ref
+		} else {
+			head := g.ReadGitDirFileExitOnError("HEAD")
+			if ref, found := strings.CutPrefix(head, "ref: "); found {
+				g.HeadRef = ref
+			} else {
+				g.HeadSha = head
+			}
+		}
+	}
+	if strings.HasPrefix(g.HeadRef, "refs/heads/") {
+		g.Branch = strings.TrimPrefix(g.HeadRef, "refs/heads/")
+	}
+	g.hasReadHead = true
+	return nil
+}
+
+// Collect gathers the state of the repository that BranchInfo and
+// BranchStatus format. The state of the working tree, the commit of HEAD, and
+// the upstream of the branch and how far ahead and behind it is are read from
+// a single git status --porcelain=v2 --branch. The git commands have no
+// dependencies on each other, so they are run concurrently and their results
+// are stored in g in a fixed order once every command has completed. Collect
+// must be called after RevParse. If a git command is killed because ctx reached
+// its deadline, TimedOut is set and the status of the working tree is left
+// unknown rather than returning an error.
+func (g *GitRepo) Collect(ctx context.Context) error {
+	if err := g.ReadHead(ctx); err != nil {
+		return err
+	}
+
+	var (
+		grp    group
+		tag    string
+		tagErr error
+		sparse bool
+		ws     workingTreeStatus
+	)
+
+	// the configuration is read before the goroutines start, since it is
+	// cached in g. If it cannot be read natively, gitCfg is nil and git is run.
+	gitCfg, _ := g.readConfig()
+
+	if g.HeadRef == "" {
+		grp.Go(func() error {
+			tag, tagErr = g.describeTag(ctx, gitCfg)
+			return nil
+		})
+	}
+
+	grp.Go(func() error {
+		var err error
+		sparse, err = g.sparseCheckout(ctx, gitCfg)
+		return err
+	})
+
+	grp.Go(func() error {
+		var err error
+		ws, err = g.collectWorkingTreeStatus(ctx, gitCfg)
+		return err
+	})
+
+	err := grp.Wait()
+	if TimedOut(ctx, err) || TimedOut(ctx, tagErr) {
+		// the commands that were killed leave the state unknown, while a
+		// command that failed on its own is still an error
+		g.TimedOut = true
+		err = withoutTimeouts(ctx, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	g.IsSparseCheckout = sparse
+
+	if tagErr == nil {
+		g.Tag = tag
+	}
+
+	g.HasUpstream = ws.hasUpstream
+	switch {
+	case ws.upstream != "":
+		g.AbbrevRef = ws.upstream
+		// the name of a remote may contain a slash, e.g., team/fork, so the
+		// configured remote is stripped rather than splitting at the first
+		// slash
+		if branch, found := strings.CutPrefix(ws.upstream, ws.branchRemote+"/"); ws.branchRemote != "" && found {
+			g.UpstreamRemote, g.UpstreamBranch = ws.branchRemote, branch
+		} else {
+			g.UpstreamRemote, g.UpstreamBranch, _ = strings.Cut(ws.upstream, "/")
+		}
+	case ws.branchMerge != "":
+		remoteParts := strings.SplitN(ws.branchRemote, ":", 2)
+		if len(remoteParts) == 2 {
+			ws.branchRemote = strings.TrimSuffix(remoteParts[1], ".git")
+		}
+		g.UpstreamRemote = ws.branchRemote
+		g.UpstreamBranch = strings.TrimPrefix(ws.branchMerge, "refs/heads/")
+	}
+
+	g.IsCleanWorkingTree = true
+	if !g.TimedOut {
+		g.Status = ws.status
+		g.IsCleanWorkingTree = ws.clean
+		g.HasUntracked = ws.untracked
+		g.HasConflict = ws.unmerged
+		if g.Tag == "" {
+			// the tag is displayed in place of the branch, so the commits
+			// ahead and behind the upstream of the branch are not
+			g.Ahead, g.Behind = ws.ahead, ws.behind
+		}
+		if g.Status != nil {
+			if g.Status.Oid != "" {
+				g.HeadSha = g.Status.Oid
+			}
+			g.StagedCount = g.Status.Staged
+			g.ModifiedCount = g.Status.Modified
+			g.DeletedCount = g.Status.Deleted
+			g.RenamedCount = g.Status.Renamed
+			g.UntrackedCount = g.Status.Untracked
+			g.ConflictedCount = g.Status.Conflicted
+		}
+	}
+
+	if g.HeadSha == "" && g.HeadRef != "" && g.Status == nil && !g.TimedOut {
+		// git status --porcelain=v2 reports the commit of HEAD, without it,
+		// e.g., in a bare repository or when each command is run instead, the
+		// branch is resolved
+		g.HeadSha, err = g.headSha(ctx, gitCfg)
+		if TimedOut(ctx, err) {
+			g.TimedOut = true
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	g.StashCount, err = g.CountStash()
+	return err
+}
+
+// readMergeState reads the state of an in-progress rebase, am, merge,
+// cherry-pick, revert, or bisect from the files in the git directory.
+func (g *GitRepo) readMergeState() {
+	if g.IsGitDir("rebase-merge") {
+		g.HeadRef = g.ReadGitDirFileExitOnError("rebase-merge/head-name")
+		g.Step = g.ReadGitDirFileEmptyOnError("rebase-merge/msgnum")
+		g.Total = g.ReadGitDirFileEmptyOnError("rebase-merge/end")
+		g.MergeState = "REBASE-m"
+		if g.GitDirFileExistsExitOnError("rebase-merge/interactive") {
+			g.MergeState = "REBASE-i"
+		}
+		return
+	}
+
+	switch {
+	case g.IsGitDir("rebase-apply"):
+		g.Step = g.ReadGitDirFileEmptyOnError("rebase-apply/next")
+		g.Total = g.ReadGitDirFileEmptyOnError("rebase-apply/last")
+		switch {
+		case g.GitDirFileExistsExitOnError("rebase-apply/rebasing"):
+			g.HeadRef = g.ReadGitDirFileExitOnError("rebase-apply/head-name")
+			g.MergeState = "REBASE"
+		case g.GitDirFileExistsExitOnError("rebase-apply/applying"):
+			g.MergeState = "AM"
+		default:
+			g.MergeState = "AM/REBASE"
+		}
+	case g.GitDirFileExistsExitOnError("MERGE_HEAD"):
+		g.MergeState = "MERGING"
+	case g.GitDirFileExistsExitOnError("CHERRY_PICK_HEAD"):
+		g.MergeState = "CHERRY-PICKING"
+	case g.GitDirFileExistsExitOnError("REVERT_HEAD"):
+		g.MergeState = "REVERTING"
+	case g.GitDirFileExistsExitOnError("BISECT_LOG"):
+		g.MergeState = "BISECTING"
+	}
+}
+
+type workingTreeStatus struct {
+	status        *Status
+	clean         bool
+	untracked     bool
+	unmerged      bool
+	ahead, behind int
+	hasUpstream   bool
+	// upstream is the upstream of the branch abbreviated, e.g., origin/main.
+	upstream string
+	// branchRemote and branchMerge are the configured upstream of the
+	// branch, which is displayed if the upstream is not a remote-tracking
+	// branch, e.g., a remote URL.
+	branchRemote string
+	branchMerge  string
+}
+
+// collectWorkingTreeStatus runs git status --porcelain=v2 --branch. If git does
+// not support porcelain v2 or there is no working tree, e.g., in a bare
+// repository, the individual git commands are run instead.
+func (g *GitRepo) collectWorkingTreeStatus(ctx context.Context, gitCfg *gitConfig) (workingTreeStatus, error) {
+	hasWorkTree := g.IsInWorkTree && !g.IsInBareRepo && !*g.IsInGitDir
+	if !hasWorkTree {
+		return g.collectEachCommand(ctx, gitCfg, false)
+	}
+
+	status, err := StatusPorcelainV2(ctx)
+	if errors.Is(err, ErrPorcelainV2Unsupported) {
+		return g.collectEachCommand(ctx, gitCfg, true)
+	}
+	if err != nil {
+		return workingTreeStatus{}, err
+	}
+
+	ws := workingTreeStatus{
+		status:      status,
+		clean:       !status.HasChanges,
+		untracked:   status.HasUntracked,
+		unmerged:    status.HasUnmerged,
+		hasUpstream: status.HasAheadBehind,
+		ahead:       status.Ahead,
+		behind:      status.Behind,
+		upstream:    status.Upstream,
+	}
+	if g.MergeState == "" && g.Branch != "" {
+		// git status only reports an upstream that is a remote-tracking
+		// branch, so a remote URL is read from the configuration, as is the
+		// remote that the abbreviated upstream starts with
+		ws.branchRemote, ws.branchMerge = g.configuredUpstream(ctx, gitCfg)
+	}
+	return ws, nil
+}
+
+// configuredUpstream returns the remote and merge ref that are configured as
+// the upstream of the branch, or empty if either is not set.
+func (g *GitRepo) configuredUpstream(ctx context.Context, gitCfg *gitConfig) (string, string) {
+	remote, err := g.configValue(ctx, gitCfg, "branch."+g.Branch+".remote")
+	if err != nil {
+		return "", ""
+	}
+	merge, err := g.configValue(ctx, gitCfg, "branch."+g.Branch+".merge")
+	if err != nil {
+		return "", ""
+	}
+	return remote, merge
+}
+
+// collectEachCommand runs the git commands that git status --porcelain=v2
+// --branch replaces concurrently. The status of the working tree is only
+// collected if hasWorkTree is set, without a working tree there is nothing to
+// change, so it is clean.
+func (g *GitRepo) collectEachCommand(ctx context.Context, gitCfg *gitConfig, hasWorkTree bool) (workingTreeStatus, error) {
+	ws := workingTreeStatus{clean: !hasWorkTree}
+
+	var grp group
+	grp.Go(func() error {
+		upstream, err := RevParseUpstream(ctx)
+		if err != nil {
+			// the branch has no upstream
+			return nil
+		}
+		ws.upstream, ws.hasUpstream = upstream, true
+		if !hasWorkTree {
+			return nil
+		}
+		ws.ahead, ws.behind, err = CommitCounts(ctx)
+		return err
+	})
+	isGitDirOnly := *g.IsInGitDir && !g.IsInBareRepo
+	if g.MergeState == "" && g.Branch != "" && !isGitDirOnly {
+		grp.Go(func() error {
+			ws.branchRemote, ws.branchMerge = g.configuredUpstream(ctx, gitCfg)
+			return nil
+		})
+	}
+	if hasWorkTree {
+		grp.Go(func() error {
+			var err error
+			ws.clean, err = HasCleanWorkingTree(ctx)
+			return err
+		})
+		grp.Go(func() error {
+			var err error
+			ws.untracked, err = HasUntracked(ctx)
+			return err
+		})
+		if g.MergeState != "" {
+			grp.Go(func() error {
+				unmerged, err := LsFilesUnmerged(ctx)
+				ws.unmerged = unmerged != ""
+				return err
+			})
+		}
+	}
+	return ws, grp.Wait()
+}
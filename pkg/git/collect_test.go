@@ -0,0 +1,117 @@
+//go:build !windows
+
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectTimeoutKeepsOtherErrors(t *testing.T) {
+	dir, _ := newTestRepo(t)
+	runGit(t, dir, "tag", "v0.1.0")
+	runGit(t, dir, "commit", "--quiet", "--allow-empty", "--message=second")
+	runGit(t, dir, "checkout", "--quiet", "--detach")
+
+	// git describe never finishes and git status fails on its own
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	binDir := t.TempDir()
+	wrapper := fmt.Sprintf(`#!/bin/sh
+case "$1" in
+describe) exec sleep 5 ;;
+--no-optional-locks) echo "fatal: index file corrupt" >&2; exit 128 ;;
+esac
+exec %s "$@"
+`, gitPath)
+	if err := os.WriteFile(filepath.Join(binDir, "git"), []byte(wrapper), 0o755); err != nil { //nolint:gosec // the wrapper must be executable
+		t.Fatal(err)
+	}
+
+	ctx := WithDir(context.Background(), dir)
+	g, _, err := RevParse(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+
+	err = g.Collect(ctx)
+	if !g.TimedOut {
+		t.Errorf("expected the slow git describe to time out")
+	}
+	if err == nil || !strings.Contains(err.Error(), "exit status 128") {
+		t.Errorf("expected the error of git status, got %v", err)
+	}
+}
+
+func TestCollectUpstreamRemoteWithSlash(t *testing.T) {
+	dir, _ := newTestRepo(t)
+	runGit(t, dir, "remote", "add", "team/fork", "https://example.com/team/fork.git")
+	runGit(t, dir, "update-ref", "refs/remotes/team/fork/feature/x", "HEAD")
+	runGit(t, dir, "config", "branch.main.remote", "team/fork")
+	runGit(t, dir, "config", "branch.main.merge", "refs/heads/feature/x")
+
+	// git status --porcelain=v2 fails like versions of git before 2.11, so
+	// that each command is run instead
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	binDir := t.TempDir()
+	wrapper := fmt.Sprintf(`#!/bin/sh
+for arg; do
+	case "$arg" in
+	--porcelain=v2) echo "error: option 'porcelain' takes no value" >&2; exit 129 ;;
+	esac
+done
+exec %s "$@"
+`, gitPath)
+	if err := os.WriteFile(filepath.Join(binDir, "git"), []byte(wrapper), 0o755); err != nil { //nolint:gosec // the wrapper must be executable
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{os.Getenv("PATH"), binDir + string(os.PathListSeparator) + os.Getenv("PATH")} {
+		t.Setenv("PATH", path)
+		ctx := WithDir(context.Background(), dir)
+		g, _, err := RevParse(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Collect(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if g.UpstreamRemote != "team/fork" || g.UpstreamBranch != "feature/x" {
+			t.Errorf("expected team/fork feature/x, got %s %s", g.UpstreamRemote, g.UpstreamBranch)
+		}
+	}
+}
+
+func TestCollectCleanWithoutWorkTree(t *testing.T) {
+	dir, _ := newTestRepo(t)
+	bare := filepath.Join(t.TempDir(), "bare.git")
+	runGit(t, dir, "clone", "--quiet", "--bare", "--no-local", dir, bare)
+
+	for _, gitDir := range []string{bare, filepath.Join(dir, ".git")} {
+		ctx := WithDir(context.Background(), gitDir)
+		g, _, err := RevParse(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Collect(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if !g.IsCleanWorkingTree {
+			t.Errorf("%s: expected a repository without a working tree to be clean", gitDir)
+		}
+	}
+}
@@ -1,17 +1,87 @@
 package git
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/mikesmithgh/git-prompt-string/pkg/util"
 )
 
-func CommitCounts() (int, int, error) {
-	cmd := exec.Command(
-		"git",
+// waitDelay bounds how long a killed git command may hold its output open,
+// e.g., by a hook or fsmonitor process that inherited it.
+const waitDelay = 100 * time.Millisecond
+
+// dirKey is the key of the directory of WithDir in a context.
+type dirKey struct{}
+
+// WithDir returns a copy of ctx in which git commands are run in dir instead
+// of the current directory.
+func WithDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, dirKey{}, dir)
+}
+
+// gitCommand returns a git command that is killed when ctx is done.
+func gitCommand(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.WaitDelay = waitDelay
+	if dir, ok := ctx.Value(dirKey{}).(string); ok {
+		cmd.Dir = dir
+	}
+	return cmd
+}
+
+// TimedOut reports whether err occurred because ctx reached its deadline,
+// i.e., a git command was killed or not started because ctx was done. The
+// error of a command that failed on its own is not a timeout, even if ctx
+// reached its deadline afterwards. For errors joined by errors.Join, TimedOut
+// reports whether any of them is a timeout.
+func TimedOut(ctx context.Context, err error) bool {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return false
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if TimedOut(ctx, e) {
+				return true
+			}
+		}
+		return false
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// a killed command did not exit on its own
+		return !exitErr.Exited()
+	}
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, exec.ErrWaitDelay)
+}
+
+// withoutTimeouts returns err without the errors for which TimedOut reports
+// true. For errors joined by errors.Join, the errors of the commands that
+// failed on their own are kept, and nil is returned if there are none.
+func withoutTimeouts(ctx context.Context, err error) error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, withoutTimeouts(ctx, e))
+		}
+		return errors.Join(errs...)
+	}
+	if TimedOut(ctx, err) {
+		return nil
+	}
+	return err
+}
+
+func CommitCounts(ctx context.Context) (int, int, error) {
+	cmd := gitCommand(
+		ctx,
 		"rev-list",
 		"--left-right",
 		"--count",
@@ -30,9 +100,9 @@ func CommitCounts() (int, int, error) {
 	return ahead, behind, nil
 }
 
-func LsFilesUnmerged() (string, error) {
-	cmd := exec.Command(
-		"git",
+func LsFilesUnmerged(ctx context.Context) (string, error) {
+	cmd := gitCommand(
+		ctx,
 		"ls-files",
 		"--unmerged",
 	)
@@ -43,9 +113,9 @@ func LsFilesUnmerged() (string, error) {
 	return strings.TrimRight(string(stdCombined), "\r\n"), err
 }
 
-func SparseCheckout() (bool, error) {
-	cmd := exec.Command(
-		"git",
+func SparseCheckout(ctx context.Context) (bool, error) {
+	cmd := gitCommand(
+		ctx,
 		"config",
 		"--bool",
 		"core.sparseCheckout",
@@ -58,9 +128,9 @@ func SparseCheckout() (bool, error) {
 	return isSparseCheckout, nil
 }
 
-func SymbolicRef(ref string) (string, error) {
-	cmd := exec.Command(
-		"git",
+func SymbolicRef(ctx context.Context, ref string) (string, error) {
+	cmd := gitCommand(
+		ctx,
 		"symbolic-ref",
 		ref,
 	)
@@ -71,9 +141,9 @@ func SymbolicRef(ref string) (string, error) {
 	return strings.TrimRight(string(stdCombined), "\r\n"), err
 }
 
-func DescribeTag(ref string) (string, error) {
-	cmd := exec.Command(
-		"git",
+func DescribeTag(ctx context.Context, ref string) (string, error) {
+	cmd := gitCommand(
+		ctx,
 		"describe",
 		"--tags",
 		"--exact-match",
@@ -86,10 +156,10 @@ func DescribeTag(ref string) (string, error) {
 	return strings.TrimRight(string(stdCombined), "\r\n"), err
 }
 
-func HasUntracked() (bool, error) {
+func HasUntracked(ctx context.Context) (bool, error) {
 	exitCode := 0
-	cmd := exec.Command(
-		"git",
+	cmd := gitCommand(
+		ctx,
 		"ls-files",
 		"--others",
 		"--exclude-standard",
@@ -112,54 +182,49 @@ func HasUntracked() (bool, error) {
 	return exitCode == 0, nil
 }
 
-func RevParseShort() (string, []byte, error) {
-	cmd := exec.Command(
-		"git",
+// RevParseHead returns the commit of HEAD.
+func RevParseHead(ctx context.Context) (string, error) {
+	cmd := gitCommand(
+		ctx,
 		"rev-parse",
-		"--short",
-		"@{upstream}",
+		"--verify",
+		"--quiet",
+		"HEAD",
 	)
-
-	stderrPipe, err := cmd.StderrPipe()
-	if err != nil {
-		return "", nil, err
-	}
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		return "", nil, err
-	}
-	if err := cmd.Start(); err != nil {
-		return "", nil, err
-	}
-
-	stderr, err := io.ReadAll(stderrPipe)
+	stdout, err := cmd.Output()
 	if err != nil {
-		return "", stderr, err
+		return "", err
 	}
+	return strings.TrimRight(string(stdout), "\r\n"), nil
+}
 
-	stdout, err := io.ReadAll(stdoutPipe)
+// RevParseUpstream returns the upstream of the current branch abbreviated,
+// e.g., origin/main. An error is returned if the branch has no upstream or the
+// upstream does not exist.
+func RevParseUpstream(ctx context.Context) (string, error) {
+	cmd := gitCommand(
+		ctx,
+		"rev-parse",
+		"--abbrev-ref",
+		"@{upstream}",
+	)
+	stdout, err := cmd.Output()
 	if err != nil {
-		return "", stderr, err
+		return "", err
 	}
-
-	err = cmd.Wait()
-
-	return strings.TrimRight(string(stdout), "\r\n"), stderr, err
+	return strings.TrimRight(string(stdout), "\r\n"), nil
 }
 
-func RevParse() (*GitRepo, []byte, error) {
+func RevParse(ctx context.Context) (*GitRepo, []byte, error) {
 	g := GitRepo{}
-	cmd := exec.Command(
-		"git",
+	cmd := gitCommand(
+		ctx,
 		"rev-parse",
-		"--verify",
 		"--absolute-git-dir",
 		"--is-inside-git-dir",
 		"--is-inside-work-tree",
 		"--is-bare-repository",
 		"--is-shallow-repository",
-		"--abbrev-ref",
-		"@{upstream}",
 	)
 
 	stderrPipe, err := cmd.StderrPipe()
@@ -189,32 +254,61 @@ func RevParse() (*GitRepo, []byte, error) {
 	if len(stdout) > 0 {
 		result := strings.Split(strings.TrimRight(string(stdout), "\r\n"), "\n")
 		resultLen := len(result)
-		if resultLen == 5 || resultLen == 6 {
+		if resultLen == 5 {
 			g.GitDir = result[0]
 			isInGitDir, _ := strconv.ParseBool(result[1])
 			g.IsInGitDir = &isInGitDir
 			g.IsInWorkTree, _ = strconv.ParseBool(result[2])
 			g.IsInBareRepo, _ = strconv.ParseBool(result[3])
 			g.IsInShallowRepo, _ = strconv.ParseBool(result[4])
-			if resultLen == 6 {
-				g.AbbrevRef = result[5]
-				shortSha, shortStderr, shortErr := RevParseShort()
-				g.ShortSha = shortSha
-				err = errors.Join(err, shortErr)
-				stderr = append(stderr, shortStderr...)
-			}
 		} else {
-			return nil, []byte{}, fmt.Errorf("expected result length of 5 or 6, got %d", resultLen)
+			return nil, []byte{}, fmt.Errorf("expected result length of 5, got %d", resultLen)
 		}
 	}
 
 	return &g, stderr, err
 }
 
-func HasCleanWorkingTree() (bool, error) {
+// FindRepo returns the repository that contains dir without running git, for
+// when git rev-parse does not respond in time. Like git, it looks for a .git
+// directory or a .git file that points to the git directory in dir and each
+// of its parents. Repositories that are only found by git, e.g., by GIT_DIR or
+// in a bare repository, are not found and nil is returned.
+func FindRepo(dir string) *GitRepo {
+	for {
+		if filepath.Base(dir) == ".git" && util.IsDir(dir) {
+			isInGitDir := true
+			return &GitRepo{GitDir: dir, IsInGitDir: &isInGitDir}
+		}
+		dotGit := filepath.Join(dir, ".git")
+		gitDir := ""
+		if util.IsDir(dotGit) {
+			gitDir = dotGit
+		} else if content, err := util.ReadFileTrimNewline(dotGit); err == nil {
+			if path, found := strings.CutPrefix(content, "gitdir: "); found {
+				gitDir = path
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(dir, gitDir)
+				}
+			}
+		}
+		if gitDir != "" {
+			isInGitDir := false
+			return &GitRepo{GitDir: gitDir, IsInGitDir: &isInGitDir, IsInWorkTree: true}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+func HasCleanWorkingTree(ctx context.Context) (bool, error) {
 	exitCode := 0
-	cmd := exec.Command(
-		"git",
+	cmd := gitCommand(
+		ctx,
 		"diff",
 		"--no-ext-diff",
 		"--quiet",
@@ -228,8 +322,8 @@ func HasCleanWorkingTree() (bool, error) {
 		}
 	}
 	cachedExitCode := 0
-	cachedCmd := exec.Command(
-		"git",
+	cachedCmd := gitCommand(
+		ctx,
 		"diff",
 		"--cached",
 		"--no-ext-diff",
@@ -250,11 +344,21 @@ func HasCleanWorkingTree() (bool, error) {
 	return exitCode != 1 && cachedExitCode != 1, nil
 }
 
-func BranchRemote(branch string) (string, error) {
-	cmd := exec.Command(
-		"git",
+func BranchRemote(ctx context.Context, branch string) (string, error) {
+	return ConfigValue(ctx, fmt.Sprintf("branch.%s.remote", branch))
+}
+
+func BranchMerge(ctx context.Context, branch string) (string, error) {
+	return ConfigValue(ctx, fmt.Sprintf("branch.%s.merge", branch))
+}
+
+// ConfigValue returns the value of the git config variable name. An error is
+// returned if the variable is not set.
+func ConfigValue(ctx context.Context, name string) (string, error) {
+	cmd := gitCommand(
+		ctx,
 		"config",
-		fmt.Sprintf("branch.%s.remote", branch),
+		name,
 	)
 	stdCombined, err := cmd.CombinedOutput()
 	if err != nil {
@@ -264,16 +368,90 @@ func BranchRemote(branch string) (string, error) {
 	return strings.TrimRight(string(stdCombined), "\r\n"), nil
 }
 
-func BranchMerge(branch string) (string, error) {
-	cmd := exec.Command(
-		"git",
+// ConfigRegexp returns the name and value of each git config variable whose
+// name matches pattern in the order that git reads them, so later variables
+// take precedence. Variables without a value have an empty value.
+func ConfigRegexp(ctx context.Context, pattern string) ([][2]string, error) {
+	cmd := gitCommand(
+		ctx,
 		"config",
-		fmt.Sprintf("branch.%s.merge", branch),
+		"--null",
+		"--get-regexp",
+		pattern,
 	)
-	stdCombined, err := cmd.CombinedOutput()
+	stdout, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return nil, nil // no variables match pattern
+	}
+	if err != nil {
+		return nil, err
+	}
+	var vars [][2]string
+	for _, entry := range strings.Split(strings.TrimSuffix(string(stdout), "\x00"), "\x00") {
+		if entry == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(entry, "\n")
+		vars = append(vars, [2]string{name, value})
+	}
+	return vars, nil
+}
+
+// ShowToplevel returns the absolute path of the top-level directory of the
+// working tree.
+func ShowToplevel(ctx context.Context) (string, error) {
+	cmd := gitCommand(
+		ctx,
+		"rev-parse",
+		"--show-toplevel",
+	)
+	stdout, err := cmd.Output()
 	if err != nil {
 		return "", err
 	}
+	return strings.TrimRight(string(stdout), "\r\n"), nil
+}
 
-	return strings.TrimRight(string(stdCombined), "\r\n"), nil
+// IgnoredDirs returns the ignored directories of the working tree relative to
+// the directory git is run in, e.g., node_modules. The files below an ignored
+// directory are not listed.
+func IgnoredDirs(ctx context.Context) ([]string, error) {
+	cmd := gitCommand(
+		ctx,
+		"ls-files",
+		"--others",
+		"--ignored",
+		"--exclude-standard",
+		"--directory",
+		"-z",
+	)
+	stdout, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, path := range strings.Split(string(stdout), "\x00") {
+		if dir, isDir := strings.CutSuffix(path, "/"); isDir {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+// IsIgnored reports whether path is ignored by git.
+func IsIgnored(ctx context.Context, path string) (bool, error) {
+	cmd := gitCommand(
+		ctx,
+		"check-ignore",
+		"--quiet",
+		"--",
+		path,
+	)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return err == nil, err
 }
@@ -0,0 +1,548 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mikesmithgh/git-prompt-string/pkg/util"
+)
+
+// errUnsupported is returned by the native readers of the git directory when
+// the repository uses a format or feature that they do not read, e.g.,
+// reftable. The caller falls back to running git.
+var errUnsupported = errors.New("unsupported by the native reader")
+
+// maxIncludeDepth is the maximum depth of nested includes, the same as git.
+const maxIncludeDepth = 10
+
+// configVar is a git config variable. The section and key of name are lower
+// case and the subsection is unchanged, e.g., branch.Feature.remote. A
+// variable without a value, i.e., a line with only a key, has noValue set.
+type configVar struct {
+	name    string
+	value   string
+	noValue bool
+}
+
+// bool returns the value of v as a git boolean. A variable without a value is
+// true and an empty value is false.
+func (v configVar) bool() (bool, error) {
+	if v.noValue {
+		return true, nil
+	}
+	switch strings.ToLower(v.value) {
+	case "true", "yes", "on":
+		return true, nil
+	case "false", "no", "off", "":
+		return false, nil
+	}
+	n, err := strconv.Atoi(v.value)
+	if err != nil {
+		return false, fmt.Errorf("bad boolean config value '%s' for '%s'", v.value, v.name)
+	}
+	return n != 0, nil
+}
+
+// gitConfig is the git configuration of a repository in the order git reads
+// it, so later variables take precedence.
+type gitConfig struct {
+	vars []configVar
+}
+
+// get returns the last variable with the canonical name.
+func (c *gitConfig) get(name string) (configVar, bool) {
+	for i := len(c.vars) - 1; i >= 0; i-- {
+		if c.vars[i].name == name {
+			return c.vars[i], true
+		}
+	}
+	return configVar{}, false
+}
+
+// readConfig reads the git configuration without running git, in the same
+// order as git: the system configuration file, the global configuration
+// files, the configuration file of the repository and of the worktree, and the
+// GIT_CONFIG_COUNT environment variables. The result is cached, so readConfig
+// must not be called concurrently. errUnsupported is returned if git would
+// read configuration that is not read natively, e.g., git -c.
+func (g *GitRepo) readConfig() (*gitConfig, error) {
+	if !g.hasReadConfig {
+		g.gitConfig, g.gitConfigErr = g.readConfigFiles()
+		g.hasReadConfig = true
+	}
+	return g.gitConfig, g.gitConfigErr
+}
+
+func (g *GitRepo) readConfigFiles() (*gitConfig, error) {
+	if os.Getenv("GIT_CONFIG_PARAMETERS") != "" {
+		return nil, errUnsupported
+	}
+	r := configReader{g: g, config: &gitConfig{}}
+
+	noSystem, _ := configVar{value: os.Getenv("GIT_CONFIG_NOSYSTEM")}.bool()
+	if !noSystem {
+		// a git built with a prefix other than /usr reads the system
+		// configuration from elsewhere, which is not read natively
+		system, found := os.LookupEnv("GIT_CONFIG_SYSTEM")
+		if !found {
+			system = "/etc/gitconfig"
+		}
+		if err := r.readFile(system, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if global, found := os.LookupEnv("GIT_CONFIG_GLOBAL"); found {
+		if err := r.readFile(global, 0); err != nil {
+			return nil, err
+		}
+	} else {
+		home, _ := os.UserHomeDir()
+		xdgConfigHome := os.Getenv("XDG_CONFIG_HOME")
+		if xdgConfigHome == "" && home != "" {
+			xdgConfigHome = filepath.Join(home, ".config")
+		}
+		for _, global := range []string{
+			filepath.Join(xdgConfigHome, "git", "config"),
+			filepath.Join(home, ".gitconfig"),
+		} {
+			if !filepath.IsAbs(global) {
+				continue
+			}
+			if err := r.readFile(global, 0); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := r.readFile(g.CommonDirPath("config"), 0); err != nil {
+		return nil, err
+	}
+	if v, ok := r.config.get("extensions.worktreeconfig"); ok {
+		if worktreeConfig, _ := v.bool(); worktreeConfig {
+			if err := r.readFile(g.GitDirPath("config.worktree"), 0); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := r.readEnv(); err != nil {
+		return nil, err
+	}
+	return r.config, nil
+}
+
+// configReader appends the variables of git config files to config, reading
+// included files in place.
+type configReader struct {
+	g      *GitRepo
+	config *gitConfig
+}
+
+// readFile reads the git config file at path. A file that does not exist is
+// ignored like git does.
+func (r *configReader) readFile(path string, depth int) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return r.parse(data, path, depth)
+}
+
+// readEnv reads the variables of the GIT_CONFIG_COUNT, GIT_CONFIG_KEY_<n>, and
+// GIT_CONFIG_VALUE_<n> environment variables.
+func (r *configReader) readEnv() error {
+	count := os.Getenv("GIT_CONFIG_COUNT")
+	if count == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 0 {
+		return fmt.Errorf("bogus count in GIT_CONFIG_COUNT")
+	}
+	for i := 0; i < n; i++ {
+		key, found := os.LookupEnv(fmt.Sprintf("GIT_CONFIG_KEY_%d", i))
+		if !found || key == "" {
+			return fmt.Errorf("missing config key GIT_CONFIG_KEY_%d", i)
+		}
+		value, found := os.LookupEnv(fmt.Sprintf("GIT_CONFIG_VALUE_%d", i))
+		if !found {
+			return fmt.Errorf("missing config value GIT_CONFIG_VALUE_%d", i)
+		}
+		if err := r.add(configVar{name: canonicalConfigName(key), value: value}, "", 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// canonicalConfigName returns name with its section and key in lower case.
+func canonicalConfigName(name string) string {
+	first := strings.IndexByte(name, '.')
+	last := strings.LastIndexByte(name, '.')
+	if first < 0 {
+		return strings.ToLower(name)
+	}
+	return strings.ToLower(name[:first]) + name[first:last] + strings.ToLower(name[last:])
+}
+
+// parse parses the git config file data read from path.
+func (r *configReader) parse(data []byte, path string, depth int) error {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	section := ""
+	line := 1
+	for i := 0; i < len(data); {
+		switch c := data[i]; {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#' || c == ';':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case c == '[':
+			name, n, err := parseSectionHeader(data[i:])
+			if err != nil {
+				return fmt.Errorf("bad config line %d in file %s", line, path)
+			}
+			section = name
+			i += n
+		case isConfigKeyStart(c):
+			if section == "" {
+				return fmt.Errorf("bad config line %d in file %s", line, path)
+			}
+			v, n, err := parseVariable(data[i:])
+			if err != nil {
+				return fmt.Errorf("bad config line %d in file %s", line, path)
+			}
+			v.name = section + "." + v.name
+			line += strings.Count(string(data[i:i+n]), "\n")
+			i += n
+			if err := r.add(v, path, depth); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("bad config line %d in file %s", line, path)
+		}
+	}
+	return nil
+}
+
+// parseSectionHeader parses a section header, e.g., [branch "main"], and
+// returns the canonical name of the section and the number of bytes read.
+func parseSectionHeader(data []byte) (string, int, error) {
+	i := 1
+	start := i
+	for i < len(data) && (isConfigKeyChar(data[i]) || data[i] == '.') {
+		i++
+	}
+	if i == start {
+		return "", 0, errors.New("empty section name")
+	}
+	// the deprecated [section.subsection] syntax is case insensitive
+	name := strings.ToLower(string(data[start:i]))
+
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t') {
+		i++
+	}
+	if i < len(data) && data[i] == '"' {
+		var sb strings.Builder
+		for i++; i < len(data) && data[i] != '"'; i++ {
+			switch data[i] {
+			case '\n':
+				return "", 0, errors.New("newline in subsection")
+			case '\\':
+				i++
+				if i == len(data) || data[i] == '\n' {
+					return "", 0, errors.New("newline in subsection")
+				}
+			}
+			sb.WriteByte(data[i])
+		}
+		if i == len(data) {
+			return "", 0, errors.New("unterminated subsection")
+		}
+		name += "." + sb.String()
+		i++
+	}
+	if i == len(data) || data[i] != ']' {
+		return "", 0, errors.New("unterminated section")
+	}
+	return name, i + 1, nil
+}
+
+// parseVariable parses a variable, e.g., remote = origin, and returns the
+// variable with its key in lower case and the number of bytes read, up to
+// and excluding the newline that ends the variable.
+func parseVariable(data []byte) (configVar, int, error) {
+	i := 0
+	for i < len(data) && isConfigKeyChar(data[i]) {
+		i++
+	}
+	v := configVar{name: strings.ToLower(string(data[:i]))}
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\r') {
+		i++
+	}
+	if i == len(data) || data[i] == '\n' || data[i] == '#' || data[i] == ';' {
+		v.noValue = true
+		for i < len(data) && data[i] != '\n' {
+			i++
+		}
+		return v, i, nil
+	}
+	if data[i] != '=' {
+		return v, 0, errors.New("expected =")
+	}
+	i++
+
+	var sb strings.Builder
+	quoted := false
+	spaces := 0
+	for ; i < len(data); i++ {
+		c := data[i]
+		if c == '\n' {
+			if quoted {
+				return v, 0, errors.New("newline in quoted value")
+			}
+			break
+		}
+		if !quoted {
+			if c == ' ' || c == '\t' || c == '\r' {
+				if sb.Len() > 0 {
+					spaces++
+				}
+				continue
+			}
+			if c == '#' || c == ';' {
+				for i < len(data) && data[i] != '\n' {
+					i++
+				}
+				break
+			}
+		}
+		for ; spaces > 0; spaces-- {
+			sb.WriteByte(' ')
+		}
+		switch c {
+		case '\\':
+			i++
+			if i == len(data) {
+				return v, 0, errors.New("unterminated escape")
+			}
+			switch data[i] {
+			case '\n':
+				// line continuation
+			case 't':
+				sb.WriteByte('\t')
+			case 'b':
+				sb.WriteByte('\b')
+			case 'n':
+				sb.WriteByte('\n')
+			case '\\', '"':
+				sb.WriteByte(data[i])
+			default:
+				return v, 0, fmt.Errorf("bad escape \\%c", data[i])
+			}
+		case '"':
+			quoted = !quoted
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if quoted {
+		return v, 0, errors.New("unterminated quote")
+	}
+	v.value = sb.String()
+	return v, i, nil
+}
+
+func isConfigKeyStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isConfigKeyChar(c byte) bool {
+	return isConfigKeyStart(c) || c >= '0' && c <= '9' || c == '-'
+}
+
+// add appends v to the configuration and reads the file that v includes, if
+// any. path is the file that v was read from and is empty for environment
+// variables.
+func (r *configReader) add(v configVar, path string, depth int) error {
+	r.config.vars = append(r.config.vars, v)
+
+	var condition string
+	switch {
+	case v.name == "include.path":
+	case strings.HasPrefix(v.name, "includeif.") && strings.HasSuffix(v.name, ".path"):
+		condition = strings.TrimSuffix(strings.TrimPrefix(v.name, "includeif."), ".path")
+	default:
+		return nil
+	}
+	if v.noValue {
+		return fmt.Errorf("missing value for '%s'", v.name)
+	}
+	if condition != "" {
+		ok, err := r.includeCondition(condition, path)
+		if err != nil || !ok {
+			return err
+		}
+	}
+	if depth >= maxIncludeDepth {
+		return fmt.Errorf("exceeded maximum include depth (%d) while including %s", maxIncludeDepth, v.value)
+	}
+
+	include, err := expandHome(v.value)
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(include) {
+		if path == "" {
+			return errUnsupported
+		}
+		include = filepath.Join(filepath.Dir(path), include)
+	}
+	return r.readFile(include, depth+1)
+}
+
+// includeCondition reports whether the condition of an includeIf section
+// applies to the repository. Unknown conditions never apply, like git.
+func (r *configReader) includeCondition(condition string, path string) (bool, error) {
+	switch {
+	case strings.HasPrefix(condition, "gitdir:"):
+		return r.includeGitDir(strings.TrimPrefix(condition, "gitdir:"), path, false)
+	case strings.HasPrefix(condition, "gitdir/i:"):
+		return r.includeGitDir(strings.TrimPrefix(condition, "gitdir/i:"), path, true)
+	case strings.HasPrefix(condition, "onbranch:"):
+		head, err := r.g.ReadGitDirFile("HEAD")
+		if err != nil {
+			return false, errUnsupported
+		}
+		branch, found := strings.CutPrefix(head, "ref: refs/heads/")
+		if !found {
+			return false, nil
+		}
+		re, err := util.GlobRegexp(strings.TrimPrefix(condition, "onbranch:"))
+		if err != nil {
+			return false, errUnsupported
+		}
+		return re.MatchString(branch), nil
+	case strings.HasPrefix(condition, "hasconfig:"):
+		return false, errUnsupported
+	}
+	return false, nil
+}
+
+// includeGitDir reports whether the git directory matches the pattern of a
+// gitdir condition. A relative pattern matches at any depth and a pattern
+// starting with ./ is relative to the directory of the file at path.
+func (r *configReader) includeGitDir(pattern string, path string, foldCase bool) (bool, error) {
+	switch {
+	case strings.HasPrefix(pattern, "./"):
+		if path == "" {
+			return false, errUnsupported
+		}
+		pattern = filepath.ToSlash(filepath.Dir(path)) + pattern[1:]
+	case pattern != "~" && !strings.HasPrefix(pattern, "~/") && !strings.HasPrefix(pattern, "/"):
+		pattern = "**/" + pattern
+	}
+	re, err := util.GlobRegexp(pattern)
+	if err != nil {
+		return false, errUnsupported
+	}
+	if foldCase {
+		re, err = regexp.Compile("(?i)" + re.String())
+		if err != nil {
+			return false, errUnsupported
+		}
+	}
+	if re.MatchString(filepath.ToSlash(r.g.GitDir)) {
+		return true, nil
+	}
+	realGitDir, err := filepath.EvalSymlinks(r.g.GitDir)
+	return err == nil && re.MatchString(filepath.ToSlash(realGitDir)), nil
+}
+
+// expandHome replaces a leading ~/ of path with the home directory.
+func expandHome(path string) (string, error) {
+	if rest, found := strings.CutPrefix(path, "~/"); found {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, rest), nil
+	}
+	return path, nil
+}
+
+// configValue returns the value of the git config variable name, running git
+// config if cfg is nil. An error is returned if the variable is not set.
+func (g *GitRepo) configValue(ctx context.Context, cfg *gitConfig, name string) (string, error) {
+	if cfg == nil {
+		return ConfigValue(ctx, name)
+	}
+	v, found := cfg.get(name)
+	if !found {
+		return "", fmt.Errorf("git config variable %s is not set", name)
+	}
+	return v.value, nil
+}
+
+// sparseCheckout reports whether core.sparseCheckout is enabled, running git
+// config if cfg is nil.
+func (g *GitRepo) sparseCheckout(ctx context.Context, cfg *gitConfig) (bool, error) {
+	if cfg == nil {
+		return SparseCheckout(ctx)
+	}
+	v, found := cfg.get("core.sparsecheckout")
+	if !found {
+		return false, nil
+	}
+	return v.bool()
+}
+
+// ConfigRegexp returns the name and value of each git config variable whose
+// name matches pattern, like the ConfigRegexp function, but reads the
+// configuration without running git when possible.
+func (g *GitRepo) ConfigRegexp(ctx context.Context, pattern string) ([][2]string, error) {
+	cfg, err := g.readConfig()
+	if err != nil {
+		return ConfigRegexp(ctx, pattern)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var vars [][2]string
+	for _, v := range cfg.vars {
+		if re.MatchString(v.name) {
+			vars = append(vars, [2]string{v.name, v.value})
+		}
+	}
+	return vars, nil
+}
+
+// RemoteURLs returns the URL of each remote.
+func (g *GitRepo) RemoteURLs(ctx context.Context) ([]string, error) {
+	vars, err := g.ConfigRegexp(ctx, `^remote\..*\.url$`)
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]string, 0, len(vars))
+	for _, v := range vars {
+		urls = append(urls, v[1])
+	}
+	return urls, nil
+}
@@ -0,0 +1,169 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// runGit runs git in dir and returns its output.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %s: %s", strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+// newTestRepo creates a repository named repo in a temporary directory with a
+// single commit on main, isolated from the system and global git
+// configuration.
+func newTestRepo(t *testing.T) (string, *GitRepo) {
+	t.Helper()
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(t.TempDir(), "gitconfig"))
+	t.Setenv("GIT_CONFIG_PARAMETERS", "")
+	t.Setenv("GIT_CONFIG_COUNT", "")
+	t.Setenv("GIT_AUTHOR_NAME", "git-prompt-string")
+	t.Setenv("GIT_AUTHOR_EMAIL", "git-prompt-string@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "git-prompt-string")
+	t.Setenv("GIT_COMMITTER_EMAIL", "git-prompt-string@example.com")
+
+	dir := filepath.Join(t.TempDir(), "repo")
+	runGit(t, t.TempDir(), "init", "--quiet", "--initial-branch=main", dir)
+	runGit(t, dir, "commit", "--quiet", "--allow-empty", "--message=initial")
+	return dir, &GitRepo{GitDir: filepath.Join(dir, ".git")}
+}
+
+func TestReadConfig(t *testing.T) {
+	dir, g := newTestRepo(t)
+	gitDir := g.GitDir
+
+	files := map[string]string{
+		"included.config": "[included]\n\tkey = from include\n[include]\n\tpath = nested.config\n",
+		"nested.config":   "[included]\n\tkey = from nested include\n",
+		"gitdir.config":   "[conditional]\n\tgitdir = yes\n",
+		"gitdiri.config":  "[conditional]\n\tgitdiri = yes\n",
+		"onbranch.config": "[conditional]\n\tonbranch = yes\n",
+		"other.config":    "[conditional]\n\tother = yes\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(gitDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.OpenFile(filepath.Join(gitDir, "config"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteString(`# comment
+; another comment
+[Branch "Main"]
+	Remote = origin ; inline comment
+	merge = "refs/heads/main" # inline comment
+[alias]
+	lg = "log --graph \
+--oneline"
+	quoted = "a \"b\" \\ c\td"
+	partly = one" two "three
+	empty =
+	flag
+[section.SubSection]
+	key = deprecated subsection
+[section "sub\"section"]
+	key = escaped subsection
+[include]
+	path = included.config
+[includeIf "gitdir:**/repo/"]
+	path = gitdir.config
+[includeIf "gitdir/i:**/REPO/.GIT"]
+	path = gitdiri.config
+[includeIf "onbranch:ma*"]
+	path = onbranch.config
+[includeIf "onbranch:other"]
+	path = other.config
+[prompt-string]
+	color-clean = green
+	color-clean = cyan
+`)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := g.readConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var actual []string
+	for _, v := range cfg.vars {
+		if v.noValue {
+			actual = append(actual, v.name)
+		} else {
+			actual = append(actual, v.name+"\n"+v.value)
+		}
+	}
+
+	out := runGit(t, dir, "config", "--list", "--includes", "--null")
+	expected := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected the variables of git config --list\n%q\ngot\n%q", expected, actual)
+	}
+
+	v, found := cfg.get("prompt-string.color-clean")
+	if !found || v.value != "cyan" {
+		t.Errorf("expected the last color-clean variable, got %+v", v)
+	}
+}
+
+func TestReadConfigUnsupported(t *testing.T) {
+	_, g := newTestRepo(t)
+	t.Setenv("GIT_CONFIG_PARAMETERS", "'core.bare'='false'")
+	if _, err := g.readConfig(); err != errUnsupported {
+		t.Errorf("expected git -c configuration to be unsupported, got %v", err)
+	}
+}
+
+func TestReadConfigBadLine(t *testing.T) {
+	_, g := newTestRepo(t)
+	if err := os.WriteFile(g.GitDirPath("config"), []byte("[core]\n\tbare = false\n[broken\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := g.readConfig()
+	expected := "bad config line 3 in file " + g.GitDirPath("config")
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestConfigVarBool(t *testing.T) {
+	tests := []struct {
+		v        configVar
+		expected bool
+		isErr    bool
+	}{
+		{configVar{noValue: true}, true, false},
+		{configVar{value: ""}, false, false},
+		{configVar{value: "yes"}, true, false},
+		{configVar{value: "On"}, true, false},
+		{configVar{value: "false"}, false, false},
+		{configVar{value: "0"}, false, false},
+		{configVar{value: "2"}, true, false},
+		{configVar{value: "maybe"}, false, true},
+	}
+	for _, test := range tests {
+		actual, err := test.v.bool()
+		if (err != nil) != test.isErr || actual != test.expected {
+			t.Errorf("%+v: expected %t (error %t), got %t (%v)", test.v, test.expected, test.isErr, actual, err)
+		}
+	}
+}
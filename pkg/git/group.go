@@ -0,0 +1,37 @@
+package git
+
+import (
+	"errors"
+	"sync"
+)
+
+// group runs functions in their own goroutines and joins their errors, in the
+// style of golang.org/x/sync/errgroup. Unlike errgroup, a failing function
+// does not cancel the others and Wait reports every error in the order the
+// functions were started.
+type group struct {
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	errs []error
+}
+
+func (gr *group) Go(f func() error) {
+	gr.mu.Lock()
+	i := len(gr.errs)
+	gr.errs = append(gr.errs, nil)
+	gr.mu.Unlock()
+
+	gr.wg.Add(1)
+	go func() {
+		defer gr.wg.Done()
+		err := f()
+		gr.mu.Lock()
+		gr.errs[i] = err
+		gr.mu.Unlock()
+	}()
+}
+
+func (gr *group) Wait() error {
+	gr.wg.Wait()
+	return errors.Join(gr.errs...)
+}
@@ -0,0 +1,239 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mikesmithgh/git-prompt-string/pkg/util"
+)
+
+// packedRef is a ref of the packed-refs file. peeled is the object that an
+// annotated tag points to, if known.
+type packedRef struct {
+	name   string
+	oid    string
+	peeled string
+}
+
+// refsSupported reports whether the refs of the repository are stored as loose
+// refs and packed-refs, rather than another format such as reftable.
+func (g *GitRepo) refsSupported(cfg *gitConfig) bool {
+	if v, ok := cfg.get("extensions.refstorage"); ok && v.value != "files" {
+		return false
+	}
+	return !util.IsDir(g.CommonDirPath("reftable"))
+}
+
+// readPackedRefs returns the refs of the packed-refs file and whether the
+// annotated tags of the file are peeled.
+func (g *GitRepo) readPackedRefs() ([]packedRef, bool, error) {
+	data, err := os.ReadFile(g.CommonDirPath("packed-refs"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var refs []packedRef
+	peeled := false
+	for i, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "# pack-refs with:"):
+			if i == 0 {
+				traits := strings.Fields(strings.TrimPrefix(line, "# pack-refs with:"))
+				for _, trait := range traits {
+					peeled = peeled || trait == "peeled" || trait == "fully-peeled"
+				}
+			}
+		case strings.HasPrefix(line, "^"):
+			if len(refs) == 0 {
+				return nil, false, fmt.Errorf("unexpected peeled line in packed-refs: %s", line)
+			}
+			refs[len(refs)-1].peeled = strings.TrimPrefix(line, "^")
+		case line == "":
+		default:
+			oid, name, found := strings.Cut(line, " ")
+			if !found {
+				return nil, false, fmt.Errorf("unexpected line in packed-refs: %s", line)
+			}
+			refs = append(refs, packedRef{name: name, oid: oid})
+		}
+	}
+	return refs, peeled, nil
+}
+
+// looseRefs returns the object of each loose ref under the directory of refs
+// in the common git directory, e.g., refs/tags, keyed by the name of the ref.
+func (g *GitRepo) looseRefs(dir string) (map[string]string, error) {
+	refs := map[string]string{}
+	root := g.CommonDirPath("")
+	err := filepath.WalkDir(g.CommonDirPath(dir), func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		oid := strings.TrimRight(string(content), "\r\n")
+		if strings.HasPrefix(oid, "ref: ") {
+			// a symbolic ref under refs/tags is unusual enough to leave to git
+			return errUnsupported
+		}
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		refs[filepath.ToSlash(name)] = oid
+		return nil
+	})
+	return refs, err
+}
+
+// tagAtHead returns the tag that points at HEAD, the same tag as git describe
+// --tags --exact-match HEAD, by reading the refs of the repository. Like git
+// describe, an annotated tag is preferred over a lightweight tag. Whether a
+// tag is annotated and the object it points to are only known from the peeled
+// lines of packed-refs, so errUnsupported is returned if git must be run
+// instead, e.g., for a loose tag that does not point at HEAD or for more than
+// one annotated tag, which git describe orders by date.
+func (g *GitRepo) tagAtHead(cfg *gitConfig) (string, error) {
+	if cfg == nil || g.HeadSha == "" || !g.refsSupported(cfg) {
+		return "", errUnsupported
+	}
+
+	packed, isPeeled, err := g.readPackedRefs()
+	if err != nil {
+		return "", err
+	}
+	loose, err := g.looseRefs("refs/tags")
+	if err != nil {
+		return "", err
+	}
+
+	tags := map[string]packedRef{}
+	for _, ref := range packed {
+		if strings.HasPrefix(ref.name, "refs/tags/") {
+			tags[ref.name] = ref
+		}
+	}
+	for name, oid := range loose {
+		tags[name] = packedRef{name: name, oid: oid}
+	}
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lightweight, annotated []string
+	for _, name := range names {
+		ref := tags[name]
+		_, isLoose := loose[name]
+		switch {
+		case ref.peeled != "":
+			if ref.peeled == g.HeadSha {
+				annotated = append(annotated, name)
+			}
+		case ref.oid == g.HeadSha:
+			lightweight = append(lightweight, name)
+		case !isLoose && isPeeled:
+			// packed-refs lists the peeled object of every annotated tag
+		default:
+			return "", errUnsupported
+		}
+	}
+
+	switch {
+	case len(annotated) > 1:
+		return "", errUnsupported
+	case len(annotated) == 1:
+		return strings.TrimPrefix(annotated[0], "refs/tags/"), nil
+	case len(lightweight) > 0:
+		return strings.TrimPrefix(lightweight[0], "refs/tags/"), nil
+	}
+	return "", fmt.Errorf("no tag exactly matches '%s'", g.HeadSha)
+}
+
+// describeTag returns the tag that points at HEAD. The refs are read natively
+// unless the repository is not supported by the native reader, in which case
+// git describe is run.
+func (g *GitRepo) describeTag(ctx context.Context, cfg *gitConfig) (string, error) {
+	tag, err := g.tagAtHead(cfg)
+	if errors.Is(err, errUnsupported) {
+		return DescribeTag(ctx, "HEAD")
+	}
+	return tag, err
+}
+
+// resolveRef returns the object of the ref name, e.g., refs/heads/main, read
+// from its loose ref or packed-refs, or empty if the ref does not exist, e.g.,
+// the branch of a repository without commits. errUnsupported is returned if
+// git must be run instead, e.g., for a symbolic ref.
+func (g *GitRepo) resolveRef(cfg *gitConfig, name string) (string, error) {
+	if cfg == nil || !g.refsSupported(cfg) || !strings.HasPrefix(name, "refs/heads/") {
+		return "", errUnsupported
+	}
+	content, err := os.ReadFile(g.CommonDirPath(name))
+	if err == nil {
+		oid := strings.TrimRight(string(content), "\r\n")
+		if strings.HasPrefix(oid, "ref: ") {
+			return "", errUnsupported
+		}
+		return oid, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	packed, _, err := g.readPackedRefs()
+	if err != nil {
+		return "", err
+	}
+	for _, ref := range packed {
+		if ref.name == name {
+			return ref.oid, nil
+		}
+	}
+	return "", nil
+}
+
+// headSha returns the commit of HEAD. The branch is resolved natively unless
+// the repository is not supported by the native reader, in which case git
+// rev-parse is run.
+func (g *GitRepo) headSha(ctx context.Context, cfg *gitConfig) (string, error) {
+	oid, err := g.resolveRef(cfg, g.HeadRef)
+	if errors.Is(err, errUnsupported) {
+		oid, err = RevParseHead(ctx)
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			// HEAD does not point to a commit yet
+			return "", nil
+		}
+	}
+	return oid, err
+}
+
+// symbolicHead returns the ref that HEAD points to when HEAD is a symbolic
+// link, which git supports for backwards compatibility. git symbolic-ref is
+// only run if the link does not point to a ref in the git directory.
+func (g *GitRepo) symbolicHead(ctx context.Context) (string, error) {
+	target, err := os.Readlink(g.GitDirPath("HEAD"))
+	if err == nil && filepath.IsAbs(target) {
+		target, err = filepath.Rel(g.GitDir, target)
+	}
+	if err == nil && strings.HasPrefix(filepath.ToSlash(target), "refs/") {
+		return filepath.ToSlash(target), nil
+	}
+	return SymbolicRef(ctx, "HEAD")
+}
@@ -0,0 +1,160 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTagAtHead(t *testing.T) {
+	dir, g := newTestRepo(t)
+	runGit(t, dir, "commit", "--quiet", "--allow-empty", "--message=second")
+	g.HeadSha = strings.TrimSpace(runGit(t, dir, "rev-parse", "HEAD"))
+
+	tagAt := func(date string, args ...string) {
+		t.Helper()
+		t.Setenv("GIT_COMMITTER_DATE", date)
+		runGit(t, dir, append([]string{"tag"}, args...)...)
+	}
+
+	// native is whether the tag is known without running git
+	steps := []struct {
+		name   string
+		native bool
+		run    func()
+	}{
+		{"no tags", true, func() {}},
+		{"lightweight tags", true, func() {
+			tagAt("2024-01-01T00:00:00Z", "v1.0.0")
+			tagAt("2024-01-01T00:00:00Z", "release/v1.0.0")
+		}},
+		{"loose tag on another commit", false, func() { tagAt("2024-01-01T00:00:00Z", "v0.1.0", "HEAD~1") }},
+		{"packed tag on another commit", true, func() { runGit(t, dir, "pack-refs", "--all") }},
+		{"loose annotated tag", false, func() { tagAt("2024-02-01T00:00:00Z", "--annotate", "--message=b", "b-annotated") }},
+		{"packed annotated tag", true, func() { runGit(t, dir, "pack-refs", "--all") }},
+		{"nested annotated tag", true, func() {
+			tagAt("2024-04-01T00:00:00Z", "--annotate", "--message=nested", "nested", "b-annotated")
+			runGit(t, dir, "tag", "--delete", "b-annotated")
+			runGit(t, dir, "pack-refs", "--all")
+		}},
+		{"annotated tags ordered by date", false, func() {
+			tagAt("2023-01-01T00:00:00Z", "--annotate", "--message=a", "a-annotated")
+			runGit(t, dir, "pack-refs", "--all")
+		}},
+		{"loose ref overrides packed ref", false, func() {
+			runGit(t, dir, "tag", "--delete", "a-annotated")
+			runGit(t, dir, "pack-refs", "--all")
+			tagAt("2024-05-01T00:00:00Z", "--force", "nested", "HEAD~1")
+		}},
+		{"deleted tags", true, func() {
+			runGit(t, dir, "tag", "--delete", "nested")
+		}},
+	}
+
+	for _, step := range steps {
+		step.run()
+		cfg, err := g.readConfig()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", step.name, err)
+		}
+
+		cmd := exec.Command("git", "describe", "--tags", "--exact-match", "HEAD")
+		cmd.Dir = dir
+		out, gitErr := cmd.Output()
+		expected := strings.TrimSpace(string(out))
+
+		actual, err := g.tagAtHead(cfg)
+		switch {
+		case !step.native && !errors.Is(err, errUnsupported):
+			t.Errorf("%s: expected git to be run, got %q, %v", step.name, actual, err)
+		case !step.native:
+		case errors.Is(err, errUnsupported):
+			t.Errorf("%s: expected the repository to be read natively", step.name)
+		case gitErr != nil && err == nil:
+			t.Errorf("%s: expected no tag, got %s", step.name, actual)
+		case gitErr == nil && err != nil:
+			t.Errorf("%s: expected %s, got error %s", step.name, expected, err)
+		case actual != expected:
+			t.Errorf("%s: expected %s, got %s", step.name, expected, actual)
+		}
+	}
+}
+
+func TestTagAtHeadUnpeeled(t *testing.T) {
+	dir, g := newTestRepo(t)
+	g.HeadSha = strings.TrimSpace(runGit(t, dir, "rev-parse", "HEAD"))
+	other := "24afc95d9b9ba2d2d2afc6fb3c6e4e5e5c4a9c6e"
+
+	// packed-refs written by old versions of git has no peeled lines
+	packedRefs := "# pack-refs with: sorted \n" +
+		g.HeadSha + " refs/tags/v1.0.0\n" +
+		other + " refs/tags/v0.1.0\n"
+	if err := os.WriteFile(filepath.Join(dir, ".git", "packed-refs"), []byte(packedRefs), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ := g.readConfig()
+	if _, err := g.tagAtHead(cfg); !errors.Is(err, errUnsupported) {
+		t.Errorf("expected a tag that is not peeled to be unsupported, got %v", err)
+	}
+}
+
+func TestTagAtHeadReftable(t *testing.T) {
+	_, g := newTestRepo(t)
+	g.HeadSha = "24afc95d9b9ba2d2d2afc6fb3c6e4e5e5c4a9c6e"
+	g.gitConfig = &gitConfig{vars: []configVar{{name: "extensions.refstorage", value: "reftable"}}}
+	g.hasReadConfig = true
+
+	cfg, _ := g.readConfig()
+	if _, err := g.tagAtHead(cfg); !errors.Is(err, errUnsupported) {
+		t.Errorf("expected reftable to be unsupported, got %v", err)
+	}
+	if _, err := g.tagAtHead(nil); !errors.Is(err, errUnsupported) {
+		t.Errorf("expected an unread configuration to be unsupported, got %v", err)
+	}
+}
+
+func TestCollectHeadShaWithoutWorkTree(t *testing.T) {
+	dir, _ := newTestRepo(t)
+	expected := strings.TrimSpace(runGit(t, dir, "rev-parse", "HEAD"))
+	bare := filepath.Join(t.TempDir(), "bare.git")
+	runGit(t, dir, "clone", "--quiet", "--bare", "--no-local", dir, bare)
+	unborn := filepath.Join(t.TempDir(), "unborn.git")
+	runGit(t, dir, "init", "--quiet", "--bare", "--initial-branch=main", unborn)
+
+	// git status is not run without a work tree, so the commit of HEAD is
+	// resolved from the refs
+	tests := []struct {
+		name     string
+		dir      string
+		run      func()
+		expected string
+	}{
+		{"packed branch", bare, func() {}, expected},
+		{"loose branch", bare, func() {
+			if err := os.WriteFile(filepath.Join(bare, "refs", "heads", "main"), []byte(expected+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}, expected},
+		{"git directory", filepath.Join(dir, ".git"), func() {}, expected},
+		{"no commits", unborn, func() {}, ""},
+	}
+
+	for _, test := range tests {
+		test.run()
+		ctx := WithDir(context.Background(), test.dir)
+		g, _, err := RevParse(ctx)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.name, err)
+		}
+		if err := g.Collect(ctx); err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.name, err)
+		}
+		if g.HeadSha != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, g.HeadSha)
+		}
+	}
+}
@@ -1,12 +1,14 @@
 package git
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/mikesmithgh/git-prompt-string/pkg/color"
 	"github.com/mikesmithgh/git-prompt-string/pkg/config"
 	"github.com/mikesmithgh/git-prompt-string/pkg/util"
 )
@@ -20,11 +22,43 @@ type GitRepo struct {
 	IsSparseCheckout           bool
 	Tag                        string
 	AbbrevRef                  string
-	ShortSha                   string
+	HasUpstream                bool // the upstream of the branch exists
+	Branch                     string
+	HeadRef                    string
+	HeadSha                    string
+	MergeState                 string
+	Step                       string
+	Total                      string
+	HasConflict                bool
+	UpstreamRemote             string
+	UpstreamBranch             string
+	IsCleanWorkingTree         bool
+	HasUntracked               bool
+	Ahead                      int
+	Behind                     int
+	StagedCount                int
+	ModifiedCount              int
+	DeletedCount               int
+	RenamedCount               int
+	UntrackedCount             int
+	ConflictedCount            int
+	StashCount                 int
+	PromptStashStatus          string
 	PromptMergeStatus          string
 	PromptSparseCheckoutStatus string
-	PromptBranch               string
+	PromptBranch               string // the branch as displayed, the names are not escaped
 	PromptBareRepoStatus       string
+	PromptDirtyStatus          string
+	PromptAheadBehindStatus    string
+	Status                     *Status // nil when git status --porcelain=v2 is unavailable
+	TimedOut                   bool    // git did not respond before the deadline, the status is unknown
+	hasReadHead                bool
+	hasReadConfig              bool
+	gitConfig                  *gitConfig // nil if the configuration must be read by git
+	gitConfigErr               error
+	promptRef                  string    // the branch, tag, or commit of PromptBranch
+	promptUpstream             [2]string // the remote and shortened branch of PromptBranch
+	noUpstreamRemoteFormat     string    // the format of promptUpstream, empty if not displayed
 }
 
 func (g *GitRepo) GitDirFileExists(name string) (bool, error) {
@@ -58,6 +92,38 @@ func (g *GitRepo) GitDirPath(path string) string {
 	return filepath.Join(g.GitDir, path)
 }
 
+// CommonDirPath returns the path of name relative to the common git directory.
+// The common git directory differs from GitDir for linked worktrees.
+func (g *GitRepo) CommonDirPath(name string) string {
+	commonDir := g.ReadGitDirFileEmptyOnError("commondir")
+	switch {
+	case commonDir == "":
+		return g.GitDirPath(name)
+	case filepath.IsAbs(commonDir):
+		return filepath.Join(commonDir, name)
+	default:
+		return filepath.Join(g.GitDir, commonDir, name)
+	}
+}
+
+// CountStash returns the number of stash entries by counting the lines of the
+// stash reflog. If the reflog does not exist but refs/stash does, the stash
+// has a single entry.
+func (g *GitRepo) CountStash() (int, error) {
+	reflog, err := os.ReadFile(g.CommonDirPath("logs/refs/stash"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	reflog = bytes.TrimRight(reflog, "\r\n")
+	if len(reflog) > 0 {
+		return bytes.Count(reflog, []byte("\n")) + 1, nil
+	}
+	if _, err := os.Stat(g.CommonDirPath("refs/stash")); err == nil {
+		return 1, nil
+	}
+	return 0, nil
+}
+
 func (g *GitRepo) ReadGitDirFile(name string) (string, error) {
 	return util.ReadFileTrimNewline(g.GitDirPath(name))
 }
@@ -78,81 +144,50 @@ func (g *GitRepo) ReadGitDirFileEmptyOnError(name string) string {
 	return content
 }
 
+// shortenBranch returns the branch shortened to the branch max length by the
+// branch abbreviation and truncation options. The branch is shortened before
+// it is escaped so that an escape sequence is never cut in half.
+func shortenBranch(branch string, cfg config.GitPromptStringConfig) (string, error) {
+	if cfg.BranchAbbreviate && (cfg.BranchMaxLength <= 0 || util.Width(branch) > cfg.BranchMaxLength) {
+		branch = util.AbbreviatePath(branch)
+	}
+	if cfg.BranchMaxLength <= 0 {
+		return branch, nil
+	}
+	return util.Truncate(branch, cfg.BranchMaxLength, cfg.BranchTruncation, cfg.BranchEllipsis)
+}
+
 func (g *GitRepo) BranchInfo(cfg config.GitPromptStringConfig) (string, error) {
-	var err error
-	ref := ""
-	step := ""
-	total := ""
-
-	if g.IsGitDir("rebase-merge") {
-		ref = g.ReadGitDirFileExitOnError("rebase-merge/head-name")
-		step = g.ReadGitDirFileEmptyOnError("rebase-merge/msgnum")
-		total = g.ReadGitDirFileEmptyOnError("rebase-merge/end")
-		g.PromptMergeStatus = "|REBASE-m"
-		if g.GitDirFileExistsExitOnError("rebase-merge/interactive") {
-			g.PromptMergeStatus = "|REBASE-i"
+	ref := g.HeadRef
+	if branch, found := strings.CutPrefix(ref, "refs/heads/"); found {
+		var err error
+		ref, err = shortenBranch(branch, cfg)
+		if err != nil {
+			return "", err
 		}
-	} else {
+	}
+	if ref == "" {
 		switch {
-		case g.IsGitDir("rebase-apply"):
-			step = g.ReadGitDirFileEmptyOnError("rebase-apply/next")
-			total = g.ReadGitDirFileEmptyOnError("rebase-apply/last")
-			switch {
-			case g.GitDirFileExistsExitOnError("rebase-apply/rebasing"):
-				ref = g.ReadGitDirFileExitOnError("rebase-apply/head-name")
-				g.PromptMergeStatus = "|REBASE"
-			case g.GitDirFileExistsExitOnError("rebase-apply/applying"):
-				g.PromptMergeStatus = "|AM"
-			default:
-				g.PromptMergeStatus = "|AM/REBASE"
-			}
-		case g.GitDirFileExistsExitOnError("MERGE_HEAD"):
-			g.PromptMergeStatus = "|MERGING"
-		case g.GitDirFileExistsExitOnError("CHERRY_PICK_HEAD"):
-			g.PromptMergeStatus = "|CHERRY-PICKING"
-		case g.GitDirFileExistsExitOnError("REVERT_HEAD"):
-			g.PromptMergeStatus = "|REVERTING"
-		case g.GitDirFileExistsExitOnError("BISECT_LOG"):
-			g.PromptMergeStatus = "|BISECTING"
+		case g.Tag != "":
+			ref = g.Tag
+		case len(g.HeadSha) > 7:
+			ref = g.HeadSha[:7]
+		default:
+			ref = g.HeadSha
 		}
+		ref = fmt.Sprintf("(%s)", ref)
+	}
 
-		if ref == "" {
-			if g.IsGitDirSymlink("HEAD") {
-				if ref, err = SymbolicRef("HEAD"); err != nil {
-					return "", err
-				}
-			} else {
-				head := g.ReadGitDirFileExitOnError("HEAD")
-				ref = strings.TrimPrefix(head, "ref: ")
-				if head == ref {
-					tag, err := DescribeTag("HEAD")
-					switch {
-					case err == nil:
-						ref = tag
-						g.Tag = ref
-					case g.ShortSha == "" && len(head) > 7:
-						ref = head[:7]
-					default:
-						ref = g.ShortSha
-					}
-					ref = fmt.Sprintf("(%s)", ref)
-				}
-			}
-		}
+	if g.MergeState != "" {
+		g.PromptMergeStatus = "|" + g.MergeState
 	}
 
-	if step != "" && total != "" {
-		g.PromptMergeStatus += fmt.Sprintf(" %s/%s", step, total)
+	if g.Step != "" && g.Total != "" {
+		g.PromptMergeStatus += fmt.Sprintf(" %s/%s", g.Step, g.Total)
 	}
 
-	if g.PromptMergeStatus != "" {
-		unmerged, err := LsFilesUnmerged()
-		if err != nil {
-			return "", err
-		}
-		if unmerged != "" {
-			g.PromptMergeStatus += "|CONFLICT"
-		}
+	if g.PromptMergeStatus != "" && g.HasConflict {
+		g.PromptMergeStatus += "|CONFLICT"
 	}
 
 	if *g.IsInGitDir {
@@ -163,40 +198,45 @@ func (g *GitRepo) BranchInfo(cfg config.GitPromptStringConfig) (string, error) {
 		}
 	}
 
-	g.PromptBranch = strings.TrimPrefix(ref, "refs/heads/")
-
-	g.IsSparseCheckout, err = SparseCheckout()
-	if err != nil {
-		return "", err
-	}
+	g.promptRef = strings.TrimPrefix(ref, "refs/heads/")
 
 	if g.IsSparseCheckout {
 		g.PromptSparseCheckoutStatus = "|SPARSE"
 	}
 
-	if g.Tag == "" && g.ShortSha == "" && g.PromptMergeStatus == "" {
-		branch_remote, err := BranchRemote(g.PromptBranch)
-		var branch_merge string
-		if err == nil {
-			branch_merge, err = BranchMerge(g.PromptBranch)
-		}
-		if err == nil {
-			remoteParts := strings.SplitN(branch_remote, ":", 2)
-			if len(remoteParts) == 2 {
-				branch_remote = strings.TrimSuffix(remoteParts[1], ".git")
-			}
-
-			if branch_merge != "" {
-				g.PromptBranch += fmt.Sprintf(cfg.NoUpstreamRemoteFormat, branch_remote, strings.TrimPrefix(branch_merge, "refs/heads/"))
-			}
+	g.noUpstreamRemoteFormat = ""
+	if g.Tag == "" && !g.HasUpstream && g.PromptMergeStatus == "" && g.UpstreamBranch != "" {
+		upstreamBranch, err := shortenBranch(g.UpstreamBranch, cfg)
+		if err != nil {
+			return "", err
 		}
+		g.promptUpstream = [2]string{g.UpstreamRemote, upstreamBranch}
+		g.noUpstreamRemoteFormat = cfg.NoUpstreamRemoteFormat
 	}
+	g.PromptBranch = g.promptBranch(func(s string) string { return s })
 
 	prompt := fmt.Sprintf("%s%s%s%s", g.PromptBareRepoStatus, g.PromptBranch, g.PromptSparseCheckoutStatus, g.PromptMergeStatus)
 
 	return prompt, nil
 }
 
+// promptBranch returns the branch as displayed with the names of the branch,
+// tag, and upstream escaped by escape.
+func (g *GitRepo) promptBranch(escape func(string) string) string {
+	branch := escape(g.promptRef)
+	if g.noUpstreamRemoteFormat != "" {
+		branch += fmt.Sprintf(g.noUpstreamRemoteFormat, escape(g.promptUpstream[0]), escape(g.promptUpstream[1]))
+	}
+	return branch
+}
+
+// EscapedPromptBranch returns PromptBranch with the names escaped by
+// color.Escape for the prompt of the shell or tmux. BranchInfo must be called
+// first.
+func (g *GitRepo) EscapedPromptBranch() string {
+	return g.promptBranch(color.Escape)
+}
+
 func (g *GitRepo) BranchStatus(cfg config.GitPromptStringConfig) (string, string, error) {
 	status := ""
 	statusColor := ""
@@ -205,22 +245,8 @@ func (g *GitRepo) BranchStatus(cfg config.GitPromptStringConfig) (string, string
 		return status, cfg.ColorNoUpstream, nil
 	}
 
-	cleanWorkingTree, err := HasCleanWorkingTree()
-	if err != nil {
-		return "", "", err
-	}
-	hasUntracked, err := HasUntracked()
-	if err != nil {
-		return "", "", err
-	}
-
-	ahead, behind := 0, 0
-	if g.Tag == "" && g.ShortSha != "" {
-		ahead, behind, err = CommitCounts()
-	}
-	if err != nil {
-		return "", "", err
-	}
+	cleanWorkingTree, hasUntracked := g.IsCleanWorkingTree, g.HasUntracked
+	ahead, behind := g.Ahead, g.Behind
 
 	if cleanWorkingTree {
 		statusColor = cfg.ColorClean
@@ -239,7 +265,7 @@ func (g *GitRepo) BranchStatus(cfg config.GitPromptStringConfig) (string, string
 		status = fmt.Sprintf(cfg.DivergedFormat, ahead, behind)
 	}
 
-	if g.ShortSha == "" {
+	if !g.HasUpstream {
 		statusColor = cfg.ColorNoUpstream
 	}
 
@@ -247,18 +273,62 @@ func (g *GitRepo) BranchStatus(cfg config.GitPromptStringConfig) (string, string
 		statusColor = cfg.ColorMerging
 	}
 
+	g.PromptAheadBehindStatus = status
+
 	if hasUntracked {
 		statusColor = cfg.ColorUntracked
-		status = fmt.Sprintf("*%s", status)
+		g.PromptDirtyStatus = "*"
 	}
 
 	if !cleanWorkingTree && !hasUntracked {
 		statusColor = cfg.ColorDirty
-		status = fmt.Sprintf("*%s", status)
+		g.PromptDirtyStatus = "*"
 	}
+
+	if g.TimedOut {
+		statusColor = cfg.ColorTimeout
+		g.PromptDirtyStatus = cfg.TimeoutMarker
+	}
+
+	if cfg.CountChanges && g.Status != nil && g.PromptDirtyStatus != "" {
+		g.PromptDirtyStatus = g.changeCounts(cfg)
+		if g.PromptAheadBehindStatus != "" {
+			g.PromptDirtyStatus += " "
+		}
+	}
+
+	status = g.PromptDirtyStatus + g.PromptAheadBehindStatus
 	if status != "" {
 		status = " " + status
 	}
 
+	if g.StashCount > 0 && cfg.StashFormat != "" {
+		g.PromptStashStatus = fmt.Sprintf(cfg.StashFormat, g.StashCount)
+		status += g.PromptStashStatus
+	}
+
 	return status, statusColor, nil
 }
+
+// changeCounts formats the non-zero change counts separated by a space, e.g.,
+// +3 ~2 -1 ?4 !1.
+func (g *GitRepo) changeCounts(cfg config.GitPromptStringConfig) string {
+	counts := []struct {
+		format string
+		count  int
+	}{
+		{cfg.StagedFormat, g.StagedCount},
+		{cfg.ModifiedFormat, g.ModifiedCount},
+		{cfg.DeletedFormat, g.DeletedCount},
+		{cfg.RenamedFormat, g.RenamedCount},
+		{cfg.UntrackedFormat, g.UntrackedCount},
+		{cfg.ConflictedFormat, g.ConflictedCount},
+	}
+	var formatted []string
+	for _, c := range counts {
+		if c.count > 0 {
+			formatted = append(formatted, fmt.Sprintf(c.format, c.count))
+		}
+	}
+	return strings.Join(formatted, " ")
+}
@@ -0,0 +1,159 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Status is the result of a single git status --porcelain=v2 --branch
+// invocation.
+type Status struct {
+	Oid             string
+	Head            string
+	Upstream        string
+	HasAheadBehind  bool
+	Ahead           int
+	Behind          int
+	HasChanges      bool
+	HasUntracked    bool
+	HasUnmerged     bool
+	IsDetached      bool
+	IsInitialCommit bool
+	Staged          int
+	Modified        int
+	Deleted         int
+	Renamed         int
+	Untracked       int
+	Conflicted      int
+}
+
+// countEntry counts an ordinary or renamed entry using its XY field. Index
+// changes are counted as staged, except for renames which are counted as
+// renamed. Work tree changes are counted as modified or deleted.
+func (s *Status) countEntry(xy string) {
+	if len(xy) != 2 {
+		return
+	}
+	switch xy[0] {
+	case '.':
+	case 'R':
+		s.Renamed++
+	default:
+		s.Staged++
+	}
+	switch xy[1] {
+	case 'M', 'T':
+		s.Modified++
+	case 'D':
+		s.Deleted++
+	}
+}
+
+// ErrPorcelainV2Unsupported is returned by StatusPorcelainV2 when git is older
+// than 2.11 and does not support git status --porcelain=v2.
+var ErrPorcelainV2Unsupported = errors.New("git status --porcelain=v2 not supported")
+
+// StatusPorcelainV2 runs git status --porcelain=v2 --branch -z and parses its
+// output.
+func StatusPorcelainV2(ctx context.Context) (*Status, error) {
+	cmd := gitCommand(
+		ctx,
+		"--no-optional-locks",
+		"status",
+		"--porcelain=v2",
+		"--branch",
+		"--untracked-files=normal",
+		"-z",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && strings.Contains(stderr.String(), "porcelain") {
+		// git before 2.11 fails with "option `porcelain' takes no value"
+		return nil, ErrPorcelainV2Unsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseStatusPorcelainV2(stdout)
+}
+
+// ParseStatusPorcelainV2 parses the NUL separated output of git status
+// --porcelain=v2 --branch -z.
+func ParseStatusPorcelainV2(data []byte) (*Status, error) {
+	s := Status{}
+	fields := bytes.Split(bytes.TrimRight(data, "\x00"), []byte{0})
+	for i := 0; i < len(fields); i++ {
+		line := string(fields[i])
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case '#':
+			key, value, _ := strings.Cut(strings.TrimPrefix(line, "# "), " ")
+			switch key {
+			case "branch.oid":
+				s.IsInitialCommit = value == "(initial)"
+				if !s.IsInitialCommit {
+					s.Oid = value
+				}
+			case "branch.head":
+				s.IsDetached = value == "(detached)"
+				if !s.IsDetached {
+					s.Head = value
+				}
+			case "branch.upstream":
+				s.Upstream = value
+			case "branch.ab":
+				ab := strings.Fields(value)
+				if len(ab) != 2 {
+					return nil, fmt.Errorf("expected branch.ab field length of 2 got %d", len(ab))
+				}
+				ahead, err := strconv.Atoi(strings.TrimPrefix(ab[0], "+"))
+				if err != nil {
+					return nil, err
+				}
+				behind, err := strconv.Atoi(strings.TrimPrefix(ab[1], "-"))
+				if err != nil {
+					return nil, err
+				}
+				s.HasAheadBehind = true
+				s.Ahead, s.Behind = ahead, behind
+			}
+		case '1':
+			s.HasChanges = true
+			s.countEntry(entryField(line, 1))
+		case '2':
+			s.HasChanges = true
+			s.countEntry(entryField(line, 1))
+			i++ // renamed and copied entries are followed by the original path
+		case 'u':
+			s.HasChanges = true
+			s.HasUnmerged = true
+			s.Conflicted++
+		case '?':
+			s.HasUntracked = true
+			s.Untracked++
+		case '!':
+			// ignored files are not reported
+		default:
+			return nil, fmt.Errorf("unexpected porcelain v2 entry %q", line)
+		}
+	}
+	return &s, nil
+}
+
+// entryField returns the nth space separated field of a porcelain v2 entry.
+func entryField(entry string, n int) string {
+	fields := strings.SplitN(entry, " ", n+2)
+	if len(fields) <= n {
+		return ""
+	}
+	return fields[n]
+}
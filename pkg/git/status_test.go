@@ -0,0 +1,107 @@
+package git
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStatusPorcelainV2(t *testing.T) {
+	const (
+		oid            = "24afc9585ad36ab4a5bcfce5fe08131e72904a5e"
+		hashes         = "100644 100644 100644 " + oid + " " + oid
+		unmergedHashes = "100644 100644 100644 100644 " + oid + " " + oid + " " + oid
+	)
+	tests := []struct {
+		name     string
+		entries  []string
+		expected Status
+		err      string
+	}{
+		{
+			"initial commit",
+			[]string{"# branch.oid (initial)", "# branch.head main"},
+			Status{IsInitialCommit: true, Head: "main"},
+			"",
+		},
+		{
+			"detached head",
+			[]string{"# branch.oid " + oid, "# branch.head (detached)"},
+			Status{Oid: oid, IsDetached: true},
+			"",
+		},
+		{
+			"upstream ahead and behind",
+			[]string{"# branch.oid " + oid, "# branch.head main", "# branch.upstream origin/main", "# branch.ab +1 -2"},
+			Status{Oid: oid, Head: "main", Upstream: "origin/main", HasAheadBehind: true, Ahead: 1, Behind: 2},
+			"",
+		},
+		{
+			"upstream gone",
+			[]string{"# branch.oid " + oid, "# branch.head main", "# branch.upstream origin/main"},
+			Status{Oid: oid, Head: "main", Upstream: "origin/main"},
+			"",
+		},
+		{
+			"ordinary changes",
+			[]string{
+				"1 .M N... " + hashes + " modified.txt",
+				"1 A. N... " + hashes + " added file.txt",
+				"1 MD N... " + hashes + " deleted.txt",
+				"1 .T N... " + hashes + " type.txt",
+			},
+			Status{HasChanges: true, Staged: 2, Modified: 2, Deleted: 1},
+			"",
+		},
+		{
+			// the original path of a rename follows the entry and is not
+			// an entry itself, even if it looks like one
+			"renamed",
+			[]string{
+				"2 R. N... " + hashes + " R100 new.txt", "? old.txt",
+				"2 RM N... " + hashes + " R90 other new.txt", "# branch.ab +5 -5",
+				"? untracked.txt",
+			},
+			Status{HasChanges: true, Renamed: 2, Modified: 1, HasUntracked: true, Untracked: 1},
+			"",
+		},
+		{
+			"unmerged",
+			[]string{"u UU N... " + unmergedHashes + " conflict.txt", "u AA N... " + unmergedHashes + " both added.txt"},
+			Status{HasChanges: true, HasUnmerged: true, Conflicted: 2},
+			"",
+		},
+		{
+			"untracked and ignored",
+			[]string{"? untracked.txt", "? dir/", "! ignored.txt"},
+			Status{HasUntracked: true, Untracked: 2},
+			"",
+		},
+		{"clean", []string{}, Status{}, ""},
+		{"bad ahead behind", []string{"# branch.ab +1"}, Status{}, "expected branch.ab field length of 2 got 1"},
+		{"bad ahead", []string{"# branch.ab +x -1"}, Status{}, `strconv.Atoi: parsing "x": invalid syntax`},
+		{"unexpected entry", []string{"3 nope"}, Status{}, `unexpected porcelain v2 entry "3 nope"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := ""
+			if len(test.entries) > 0 {
+				data = strings.Join(test.entries, "\x00") + "\x00"
+			}
+			actual, err := ParseStatusPorcelainV2([]byte(data))
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(*actual, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, *actual)
+			}
+		})
+	}
+}
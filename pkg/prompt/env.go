@@ -0,0 +1,80 @@
+package prompt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mikesmithgh/git-prompt-string/pkg/git"
+	"github.com/mikesmithgh/git-prompt-string/pkg/shell"
+)
+
+// envDialects are the shells whose variable assignments are printed by Env
+// for each value of the --shell flag.
+var envDialects = map[string]string{
+	"bash":       shell.Bash,
+	"readline":   shell.Bash,
+	"raw":        shell.Bash,
+	"zsh":        shell.Zsh,
+	"fish":       shell.Fish,
+	"powershell": shell.PowerShell,
+}
+
+// Env returns the state of the repository as variable assignments of sh, one
+// per line, e.g., GPS_BRANCH=main, to be evaluated by scripts. Booleans are 1
+// or 0 and values that do not apply, e.g., GPS_STEP outside of a rebase, are
+// empty.
+func Env(g *git.GitRepo, sh string) (string, error) {
+	dialect, ok := envDialects[sh]
+	if !ok {
+		return "", fmt.Errorf("shell %s not supported by output env, expected one of bash, readline, raw, zsh, fish, powershell", sh)
+	}
+
+	shortSha := g.HeadSha
+	if len(shortSha) > 7 {
+		shortSha = shortSha[:7]
+	}
+	boolean := func(b bool) string {
+		if b {
+			return "1"
+		}
+		return "0"
+	}
+	vars := [][2]string{
+		{"GPS_BRANCH", g.Branch},
+		{"GPS_TAG", g.Tag},
+		{"GPS_SHA", shortSha},
+		{"GPS_STATE", g.MergeState},
+		{"GPS_STEP", g.Step},
+		{"GPS_TOTAL", g.Total},
+		{"GPS_AHEAD", strconv.Itoa(g.Ahead)},
+		{"GPS_BEHIND", strconv.Itoa(g.Behind)},
+		{"GPS_DIRTY", boolean(!g.IsCleanWorkingTree)},
+		{"GPS_UNTRACKED", boolean(g.HasUntracked)},
+		{"GPS_CONFLICT", boolean(g.HasConflict)},
+		{"GPS_BARE", boolean(g.IsInBareRepo)},
+		{"GPS_SHALLOW", boolean(g.IsInShallowRepo)},
+		{"GPS_SPARSE", boolean(g.IsSparseCheckout)},
+		{"GPS_GIT_DIR", g.GitDir},
+		{"GPS_UPSTREAM_REMOTE", g.UpstreamRemote},
+		{"GPS_UPSTREAM_BRANCH", g.UpstreamBranch},
+		{"GPS_STAGED_COUNT", strconv.Itoa(g.StagedCount)},
+		{"GPS_MODIFIED_COUNT", strconv.Itoa(g.ModifiedCount)},
+		{"GPS_DELETED_COUNT", strconv.Itoa(g.DeletedCount)},
+		{"GPS_RENAMED_COUNT", strconv.Itoa(g.RenamedCount)},
+		{"GPS_UNTRACKED_COUNT", strconv.Itoa(g.UntrackedCount)},
+		{"GPS_CONFLICTED_COUNT", strconv.Itoa(g.ConflictedCount)},
+		{"GPS_STASH_COUNT", strconv.Itoa(g.StashCount)},
+		{"GPS_TIMED_OUT", boolean(g.TimedOut)},
+	}
+
+	var sb strings.Builder
+	for _, v := range vars {
+		assignment, err := shell.Assign(dialect, v[0], v[1])
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(assignment + "\n")
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,102 @@
+package prompt
+
+import (
+	"strconv"
+
+	"github.com/mikesmithgh/git-prompt-string/pkg/config"
+	"github.com/mikesmithgh/git-prompt-string/pkg/git"
+)
+
+// JSONVersion is the version of the JSON document. It is incremented when a
+// key is removed or the meaning of a value changes, not when a key is added.
+const JSONVersion = 1
+
+// JSON is the document printed by --json. The keys before version are those
+// of the first, unversioned document and are kept for compatibility, and new
+// keys are added after version.
+type JSON struct {
+	BranchInfo   string `json:"branchInfo"`   // the rendered branch, sparse, and merge state
+	BranchStatus string `json:"branchStatus"` // the rendered dirty marker, ahead/behind counts, and stash count
+	Color        string `json:"color"`        // the color of the prompt for the current status, empty when colors are disabled
+	PromptPrefix string `json:"promptPrefix"` // the configured prompt prefix
+	PromptSuffix string `json:"promptSuffix"` // the configured prompt suffix
+
+	Version         int    `json:"version"`         // JSONVersion
+	Branch          string `json:"branch"`          // the current branch, empty when HEAD is detached
+	Tag             string `json:"tag"`             // the tag pointing at HEAD when HEAD is detached
+	ShortSha        string `json:"shortSha"`        // the abbreviated commit of HEAD, empty when there are no commits
+	GitDir          string `json:"gitDir"`          // the git directory as reported by git rev-parse --git-dir
+	InsideGitDir    bool   `json:"insideGitDir"`    // the current directory is inside the git directory
+	Bare            bool   `json:"bare"`            // the repository is bare
+	Shallow         bool   `json:"shallow"`         // the repository is a shallow clone
+	Sparse          bool   `json:"sparse"`          // the repository is a sparse checkout
+	MergeState      string `json:"mergeState"`      // one of REBASE, REBASE-i, REBASE-m, AM, AM/REBASE, MERGING, CHERRY-PICKING, REVERTING, BISECTING, or empty
+	Step            int    `json:"step"`            // the current step of a rebase or am, 0 otherwise
+	Total           int    `json:"total"`           // the total steps of a rebase or am, 0 otherwise
+	Conflict        bool   `json:"conflict"`        // there are unmerged paths
+	Ahead           int    `json:"ahead"`           // commits ahead of the upstream branch
+	Behind          int    `json:"behind"`          // commits behind the upstream branch
+	Clean           bool   `json:"clean"`           // the working tree has no uncommitted changes
+	Untracked       bool   `json:"untracked"`       // the working tree has untracked files
+	UpstreamRemote  string `json:"upstreamRemote"`  // the remote of the upstream branch
+	UpstreamBranch  string `json:"upstreamBranch"`  // the upstream branch
+	StagedCount     int    `json:"stagedCount"`     // the number of staged changes
+	ModifiedCount   int    `json:"modifiedCount"`   // the number of modified files that are not staged
+	DeletedCount    int    `json:"deletedCount"`    // the number of deleted files that are not staged
+	RenamedCount    int    `json:"renamedCount"`    // the number of renamed files
+	UntrackedCount  int    `json:"untrackedCount"`  // the number of untracked files
+	ConflictedCount int    `json:"conflictedCount"` // the number of files with conflicts
+	StashCount      int    `json:"stashCount"`      // the number of stash entries
+	TimedOut        bool   `json:"timedOut"`        // git did not respond before the timeout
+}
+
+// NewJSON returns the JSON document of the repository with the rendered
+// branch info and branch status, which are not colored.
+func NewJSON(g *git.GitRepo, cfg config.GitPromptStringConfig, branchInfo, branchStatus, statusColor string) JSON {
+	if cfg.ColorDisabled {
+		statusColor = ""
+	}
+	shortSha := g.HeadSha
+	if len(shortSha) > 7 {
+		shortSha = shortSha[:7]
+	}
+	// step and total are read from files in the git directory and are 0 when
+	// the file is missing or malformed
+	step, _ := strconv.Atoi(g.Step)
+	total, _ := strconv.Atoi(g.Total)
+	return JSON{
+		BranchInfo:   branchInfo,
+		BranchStatus: branchStatus,
+		Color:        statusColor,
+		PromptPrefix: cfg.PromptPrefix,
+		PromptSuffix: cfg.PromptSuffix,
+
+		Version:         JSONVersion,
+		Branch:          g.Branch,
+		Tag:             g.Tag,
+		ShortSha:        shortSha,
+		GitDir:          g.GitDir,
+		InsideGitDir:    *g.IsInGitDir,
+		Bare:            g.IsInBareRepo,
+		Shallow:         g.IsInShallowRepo,
+		Sparse:          g.IsSparseCheckout,
+		MergeState:      g.MergeState,
+		Step:            step,
+		Total:           total,
+		Conflict:        g.HasConflict,
+		Ahead:           g.Ahead,
+		Behind:          g.Behind,
+		Clean:           g.IsCleanWorkingTree,
+		Untracked:       g.HasUntracked,
+		UpstreamRemote:  g.UpstreamRemote,
+		UpstreamBranch:  g.UpstreamBranch,
+		StagedCount:     g.StagedCount,
+		ModifiedCount:   g.ModifiedCount,
+		DeletedCount:    g.DeletedCount,
+		RenamedCount:    g.RenamedCount,
+		UntrackedCount:  g.UntrackedCount,
+		ConflictedCount: g.ConflictedCount,
+		StashCount:      g.StashCount,
+		TimedOut:        g.TimedOut,
+	}
+}
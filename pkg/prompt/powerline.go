@@ -0,0 +1,44 @@
+package prompt
+
+import (
+	"strings"
+
+	"github.com/mikesmithgh/git-prompt-string/pkg/color"
+	"github.com/mikesmithgh/git-prompt-string/pkg/config"
+	"github.com/mikesmithgh/git-prompt-string/pkg/git"
+)
+
+// Powerline renders the prompt as the branch, merge state, ahead/behind,
+// dirty, and stash segments followed by the prompt suffix. A segment is
+// displayed in its segment color, e.g., color_branch, or black on the status
+// color if the segment color is not set.
+func Powerline(g *git.GitRepo, cfg config.GitPromptStringConfig, statusColor string) (string, error) {
+	defaultColors := append([]string{"black"}, color.Background(strings.Fields(statusColor))...)
+	colors := func(segmentColor string) []string {
+		if fields := strings.Fields(segmentColor); len(fields) > 0 {
+			return fields
+		}
+		return defaultColors
+	}
+
+	// the merge and sparse states are separated by | in the branch info
+	state := strings.TrimPrefix(g.PromptSparseCheckoutStatus+g.PromptMergeStatus, "|")
+
+	segments := []color.PowerlineSegment{
+		{Text: strings.TrimSpace(cfg.PromptPrefix + g.PromptBareRepoStatus + g.EscapedPromptBranch()), Colors: colors(cfg.ColorBranch)},
+		{Text: state, Colors: colors(cfg.ColorMergeState)},
+		{Text: strings.TrimSpace(g.PromptAheadBehindStatus), Colors: colors(cfg.ColorAheadBehind)},
+		{Text: strings.TrimSpace(g.PromptDirtyStatus), Colors: colors(cfg.ColorDirtyMarker)},
+		{Text: strings.TrimSpace(g.PromptStashStatus), Colors: colors(cfg.ColorStash)},
+	}
+	output, err := color.Powerline(segments, cfg.PowerlineSeparator, cfg.PowerlineThinSeparator)
+	if err != nil {
+		return "", err
+	}
+
+	suffix, err := color.Segment(cfg.PromptSuffix, strings.Fields(cfg.ColorSuffix), nil)
+	if err != nil {
+		return "", err
+	}
+	return output + suffix, nil
+}
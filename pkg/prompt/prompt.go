@@ -0,0 +1,180 @@
+package prompt
+
+import (
+	"errors"
+	"strings"
+	"text/template"
+
+	"github.com/mikesmithgh/git-prompt-string/pkg/color"
+	"github.com/mikesmithgh/git-prompt-string/pkg/config"
+	"github.com/mikesmithgh/git-prompt-string/pkg/git"
+)
+
+// DefaultFormat renders the prompt as the prompt prefix, branch info, branch
+// status, and prompt suffix wrapped in the status color.
+const DefaultFormat = "{{color .Color}}{{.PromptPrefix}}{{.BranchInfo}}{{.BranchStatus}}{{.PromptSuffix}}{{reset}}"
+
+// Data is the model available to the format template.
+type Data struct {
+	PromptPrefix   string // the configured prompt prefix in the prefix color
+	PromptSuffix   string // the configured prompt suffix in the suffix color
+	BranchInfo     string // the rendered branch, sparse, and merge state in their segment colors
+	BranchStatus   string // the rendered dirty marker and ahead/behind counts in their segment colors
+	Color          string // the color of the prompt for the current status
+	PromptBranch   string // the branch as displayed, e.g., main or (v1.0.0)
+	Branch         string // the current branch, empty when HEAD is detached
+	Tag            string // the tag pointing at HEAD when HEAD is detached
+	ShortSha       string // the abbreviated commit of HEAD
+	MergeState     string // e.g., REBASE-i, MERGING, or BISECTING
+	Step           string // the current step of a rebase or am
+	Total          string // the total steps of a rebase or am
+	Ahead          int    // commits ahead of the upstream branch
+	Behind         int    // commits behind the upstream branch
+	Dirty          bool   // the working tree has uncommitted changes
+	Untracked      bool   // the working tree has untracked files
+	Conflict       bool   // there are unmerged paths
+	Sparse         bool   // the repository is a sparse checkout
+	Bare           bool   // the repository is bare
+	UpstreamRemote string // the remote of the upstream branch
+	UpstreamBranch string // the upstream branch
+	Staged         int    // the number of staged changes
+	Modified       int    // the number of modified files that are not staged
+	Deleted        int    // the number of deleted files that are not staged
+	Renamed        int    // the number of renamed files
+	UntrackedCount int    // the number of untracked files
+	Conflicted     int    // the number of files with conflicts
+	Stash          int    // the number of stash entries
+	TimedOut       bool   // git did not respond before the timeout
+}
+
+func NewData(g *git.GitRepo, cfg config.GitPromptStringConfig, statusColor string) (Data, error) {
+	restore := strings.Fields(statusColor)
+	var segmentErr error
+	segment := func(text string, colors string) string {
+		s, err := color.Segment(text, strings.Fields(colors), restore)
+		segmentErr = errors.Join(segmentErr, err)
+		return s
+	}
+
+	// the names are escaped so that the shell or tmux displays them as is
+	promptBranch := g.EscapedPromptBranch()
+	branchInfo := segment(g.PromptBareRepoStatus+promptBranch, cfg.ColorBranch) +
+		segment(g.PromptSparseCheckoutStatus, cfg.ColorSparse) +
+		segment(g.PromptMergeStatus, cfg.ColorMergeState)
+
+	branchStatus := ""
+	if g.PromptDirtyStatus != "" || g.PromptAheadBehindStatus != "" {
+		branchStatus = " " + segment(g.PromptDirtyStatus, cfg.ColorDirtyMarker) + segment(g.PromptAheadBehindStatus, cfg.ColorAheadBehind)
+	}
+	branchStatus += segment(g.PromptStashStatus, cfg.ColorStash)
+
+	promptPrefix := segment(cfg.PromptPrefix, cfg.ColorPrefix)
+	promptSuffix := segment(cfg.PromptSuffix, cfg.ColorSuffix)
+
+	if segmentErr != nil {
+		return Data{}, segmentErr
+	}
+
+	shortSha := g.HeadSha
+	if len(shortSha) > 7 {
+		shortSha = shortSha[:7]
+	}
+	return Data{
+		PromptPrefix:   promptPrefix,
+		PromptSuffix:   promptSuffix,
+		BranchInfo:     branchInfo,
+		BranchStatus:   branchStatus,
+		Color:          statusColor,
+		PromptBranch:   promptBranch,
+		Branch:         color.Escape(g.Branch),
+		Tag:            color.Escape(g.Tag),
+		ShortSha:       shortSha,
+		MergeState:     g.MergeState,
+		Step:           g.Step,
+		Total:          g.Total,
+		Ahead:          g.Ahead,
+		Behind:         g.Behind,
+		Dirty:          !g.IsCleanWorkingTree,
+		Untracked:      g.HasUntracked,
+		Conflict:       g.HasConflict,
+		Sparse:         g.IsSparseCheckout,
+		Bare:           g.IsInBareRepo,
+		UpstreamRemote: color.Escape(g.UpstreamRemote),
+		UpstreamBranch: color.Escape(g.UpstreamBranch),
+		Staged:         g.StagedCount,
+		Modified:       g.ModifiedCount,
+		Deleted:        g.DeletedCount,
+		Renamed:        g.RenamedCount,
+		UntrackedCount: g.UntrackedCount,
+		Conflicted:     g.ConflictedCount,
+		Stash:          g.StashCount,
+		TimedOut:       g.TimedOut,
+	}, nil
+}
+
+var funcs = template.FuncMap{
+	// color returns the escape sequence of one or more whitespace separated colors
+	"color": func(colors ...string) (string, error) {
+		return color.Color(strings.Fields(strings.Join(colors, " "))...)
+	},
+	// reset returns the escape sequence that clears all colors
+	"reset": func() (string, error) {
+		return color.Color("reset")
+	},
+	// paint wraps text in the given colors followed by a reset, text is
+	// returned unchanged when empty
+	"paint": func(colors string, text string) (string, error) {
+		if text == "" {
+			return "", nil
+		}
+		start, err := color.Color(strings.Fields(colors)...)
+		if err != nil {
+			return "", err
+		}
+		if start == "" {
+			return text, nil
+		}
+		reset, err := color.Color("reset")
+		if err != nil {
+			return "", err
+		}
+		return start + text + reset, nil
+	},
+	// when returns text if cond is not the zero value, otherwise empty
+	"when": func(cond any, text string) string {
+		if truth, _ := template.IsTrue(cond); truth {
+			return text
+		}
+		return ""
+	},
+	// prefix adds p before text if text is not empty
+	"prefix": func(p string, text string) string {
+		if text == "" {
+			return ""
+		}
+		return p + text
+	},
+	// suffix adds s after text if text is not empty
+	"suffix": func(s string, text string) string {
+		if text == "" {
+			return ""
+		}
+		return text + s
+	},
+}
+
+func Parse(format string) (*template.Template, error) {
+	return template.New("format").Funcs(funcs).Parse(format)
+}
+
+func Render(format string, data Data) (string, error) {
+	tmpl, err := Parse(format)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,58 @@
+package shell
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+//go:embed init
+var initScripts embed.FS
+
+// Shells are the shells supported by Init.
+var Shells = []string{Bash, Zsh, Fish, PowerShell, Nushell}
+
+var initExtensions = map[string]string{
+	Bash:       "bash",
+	Zsh:        "zsh",
+	Fish:       "fish",
+	PowerShell: "ps1",
+	Nushell:    "nu",
+}
+
+// promptShells are the values of the --shell flag that match how each shell
+// displays the output of git-prompt-string. bash expands the output in PS1, so
+// the readline delimiters are used instead of \[ and \].
+var promptShells = map[string]string{
+	Bash:       "readline",
+	Zsh:        "zsh",
+	Fish:       "fish",
+	PowerShell: "powershell",
+	Nushell:    "raw",
+}
+
+// Init returns the snippet that integrates git-prompt-string into the prompt
+// of shell. The prompt runs git-prompt-string with flags, e.g., the flags that
+// were passed to the init command.
+func Init(shell string, flags []string) (string, error) {
+	ext, ok := initExtensions[shell]
+	if !ok {
+		return "", fmt.Errorf("shell %s not supported, expected one of %s", shell, strings.Join(Shells, ", "))
+	}
+	tmpl, err := template.ParseFS(initScripts, fmt.Sprintf("init/%s.%s", shell, ext))
+	if err != nil {
+		return "", err
+	}
+	command := append([]string{"git-prompt-string", "--shell=" + promptShells[shell]}, flags...)
+	quoted, err := QuoteCommand(shell, command)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	err = tmpl.Execute(&sb, struct{ Command string }{quoted})
+	if err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,22 @@
+# git-prompt-string integration for bash
+#
+# Add the following to ~/.bashrc:
+#   eval "$(git-prompt-string init bash)"
+#
+# The prompt is stored in GIT_PROMPT_STRING before each prompt is displayed.
+# To use your own prompt, set PS1 after the eval and reference
+# ${GIT_PROMPT_STRING}.
+
+__git_prompt_string_hook() {
+	local exit_status=$?
+	GIT_PROMPT_STRING="$({{.Command}})"
+	return $exit_status
+}
+
+if [[ ";${PROMPT_COMMAND:-};" != *";__git_prompt_string_hook;"* ]]; then
+	PROMPT_COMMAND="__git_prompt_string_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+
+if [[ $PS1 != *GIT_PROMPT_STRING* ]]; then
+	PS1='\n\[\e[0;33m\]\w\[\e[0m\]${GIT_PROMPT_STRING}\n\[\e[0;32m\]bash \[\e[0;36m\]\$\[\e[0m\] '
+fi
@@ -0,0 +1,22 @@
+# git-prompt-string integration for fish
+#
+# Add the following to ~/.config/fish/config.fish:
+#   git-prompt-string init fish | source
+#
+# To use your own prompt, define fish_prompt after sourcing and call
+# __git_prompt_string.
+
+function __git_prompt_string
+    {{.Command}}
+end
+
+function fish_prompt
+    set -l symbol ' $ '
+    if fish_is_root_user
+        set symbol ' # '
+    end
+
+    printf '\n%s%s%s%s\n%s%sfish%s%s%s' \
+        (set_color yellow) (prompt_pwd --dir-length 0) (set_color normal) (__git_prompt_string) \
+        (set_color green) (set_color blue) (set_color cyan) $symbol (set_color normal)
+end
@@ -0,0 +1,17 @@
+# git-prompt-string integration for powershell
+#
+# Add the following to your $PROFILE:
+#   Invoke-Expression (& git-prompt-string init powershell | Out-String)
+#
+# To use your own prompt, define the prompt function after the
+# Invoke-Expression and call Get-GitPromptString.
+
+function Get-GitPromptString {
+    & {{.Command}}
+}
+
+function prompt {
+    $ESC = [char]27
+    $w = $pwd.Path.Replace($env:USER_PROFILE, "~").Replace($env:HOME, "~")
+    return "`n$ESC[0;33m$w$ESC[0m$(Get-GitPromptString)`n$ESC[0;32mPS $ESC[0;36m>$ESC[0m "
+}
@@ -0,0 +1,110 @@
+package shell
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/buildkite/shellwords"
+)
+
+const (
+	Bash       = "bash"
+	Zsh        = "zsh"
+	Fish       = "fish"
+	PowerShell = "powershell"
+	Nushell    = "nushell"
+)
+
+// safeWord matches words that do not need to be quoted in any shell.
+var safeWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// Quote returns s quoted such that shell parses it as a single word.
+func Quote(shell string, s string) (string, error) {
+	switch shell {
+	case Bash, Zsh:
+		return quotePosix(s), nil
+	case Fish:
+		if safeWord.MatchString(s) {
+			return s, nil
+		}
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'", nil
+	case PowerShell:
+		return "'" + strings.NewReplacer(`'`, `''`, "‘", "‘‘", "’", "’’").Replace(s) + "'", nil
+	case Nushell:
+		if safeWord.MatchString(s) {
+			return s, nil
+		}
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`, nil
+	default:
+		return "", fmt.Errorf("shell %s not supported", shell)
+	}
+}
+
+// quotePosix returns s quoted by shellwords.QuotePosix such that a posix shell
+// parses it as a single word. QuotePosix puts a word with whitespace in double
+// quotes, in which the backslashes of its escapes are not removed, so runs of
+// whitespace are quoted in single quotes instead and the rest by QuotePosix.
+// Safe words, e.g., --shell=readline, are not quoted at all.
+func quotePosix(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if safeWord.MatchString(s) {
+		return s
+	}
+	var sb strings.Builder
+	start := 0
+	for start < len(s) {
+		r, _ := utf8.DecodeRuneInString(s[start:])
+		isSpace := unicode.IsSpace(r)
+		end := strings.IndexFunc(s[start:], func(r rune) bool { return unicode.IsSpace(r) != isSpace })
+		if end < 0 {
+			end = len(s)
+		} else {
+			end += start
+		}
+		if isSpace {
+			sb.WriteString("'" + s[start:end] + "'")
+		} else {
+			sb.WriteString(shellwords.QuotePosix(s[start:end]))
+		}
+		start = end
+	}
+	return sb.String()
+}
+
+// QuoteCommand returns args quoted and joined such that shell parses them as
+// a single command.
+func QuoteCommand(shell string, args []string) (string, error) {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		q, err := Quote(shell, arg)
+		if err != nil {
+			return "", err
+		}
+		quoted = append(quoted, q)
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+// Assign returns the statement that sets the variable name to value in
+// shell, e.g., name='value' in bash.
+func Assign(shell string, name string, value string) (string, error) {
+	quoted, err := Quote(shell, value)
+	if err != nil {
+		return "", err
+	}
+	switch shell {
+	case Bash, Zsh:
+		return name + "=" + quoted, nil
+	case Fish:
+		return "set -g " + name + " " + quoted, nil
+	case PowerShell:
+		return "$" + name + " = " + quoted, nil
+	default:
+		return "", fmt.Errorf("shell %s not supported", shell)
+	}
+}
@@ -0,0 +1,68 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// GlobRegexp returns the regular expression of a path glob. A leading ~ is
+// the home directory, * matches any characters except /, ** matches any
+// characters including /, and a trailing / is shorthand for /**.
+func GlobRegexp(glob string) (*regexp.Regexp, error) {
+	if glob == "~" || strings.HasPrefix(glob, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		glob = filepath.ToSlash(home) + strings.TrimPrefix(glob, "~")
+	}
+	if strings.HasSuffix(glob, "/") {
+		glob += "**"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**") {
+				switch {
+				case strings.HasPrefix(glob[i:], "**/"):
+					sb.WriteString("(.*/)?")
+					i += 2
+				case i > 0 && glob[i-1] == '/' && i+2 == len(glob):
+					// the trailing /** also matches the directory itself
+					s := sb.String()
+					sb.Reset()
+					sb.WriteString(strings.TrimSuffix(s, "/") + "(/.*)?")
+					i++
+				default:
+					sb.WriteString(".*")
+					i++
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class in %s", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
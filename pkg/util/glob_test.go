@@ -0,0 +1,52 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGlobRegexp(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatalf("failed to find home: %s", err)
+	}
+	home = filepath.ToSlash(home)
+
+	tests := []struct {
+		glob     string
+		path     string
+		expected bool
+	}{
+		{"~/work/**", home + "/work", true},
+		{"~/work/**", home + "/work/org/repo", true},
+		{"~/work/**", home + "/workspace/repo", false},
+		{"~/work/", home + "/work/repo", true},
+		{"~/work/*", home + "/work/repo", true},
+		{"~/work/*", home + "/work/org/repo", false},
+		{"/src/**/repo", "/src/repo", true},
+		{"/src/**/repo", "/src/a/b/repo", true},
+		{"/src/**/repo", "/src/a/b/repo2", false},
+		{"**/repo", "/any/where/repo", true},
+		{"/src/repo?", "/src/repo1", true},
+		{"/src/repo?", "/src/repo/", false},
+		{"/src/[ab]*", "/src/alpha", true},
+		{"/src/[!ab]*", "/src/alpha", false},
+		{"/src/a.b", "/src/aXb", false},
+	}
+
+	for _, test := range tests {
+		re, err := GlobRegexp(test.glob)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.glob, err)
+			continue
+		}
+		if actual := re.MatchString(test.path); actual != test.expected {
+			t.Errorf("%s matching %s: expected %t, got %t", test.glob, test.path, test.expected, actual)
+		}
+	}
+
+	if _, err := GlobRegexp("/src/[ab"); err == nil {
+		t.Errorf("expected error for unterminated character class")
+	}
+}
@@ -0,0 +1,265 @@
+package util
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	zeroWidthJoiner = '\u200d'
+	variationEmoji  = '\ufe0f' // displays the character before it as an emoji
+)
+
+// wideRanges are the code points that are displayed in two columns, i.e., the
+// East Asian wide and fullwidth characters and the emoji that are displayed as
+// emoji by default.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f},
+	{0x231a, 0x231b},
+	{0x2329, 0x232a},
+	{0x23e9, 0x23ec},
+	{0x23f0, 0x23f0},
+	{0x23f3, 0x23f3},
+	{0x25fd, 0x25fe},
+	{0x2614, 0x2615},
+	{0x2648, 0x2653},
+	{0x267f, 0x267f},
+	{0x2693, 0x2693},
+	{0x26a1, 0x26a1},
+	{0x26aa, 0x26ab},
+	{0x26bd, 0x26be},
+	{0x26c4, 0x26c5},
+	{0x26ce, 0x26ce},
+	{0x26d4, 0x26d4},
+	{0x26ea, 0x26ea},
+	{0x26f2, 0x26f3},
+	{0x26f5, 0x26f5},
+	{0x26fa, 0x26fa},
+	{0x26fd, 0x26fd},
+	{0x2705, 0x2705},
+	{0x270a, 0x270b},
+	{0x2728, 0x2728},
+	{0x274c, 0x274c},
+	{0x274e, 0x274e},
+	{0x2753, 0x2755},
+	{0x2757, 0x2757},
+	{0x2795, 0x2797},
+	{0x27b0, 0x27b0},
+	{0x27bf, 0x27bf},
+	{0x2b1b, 0x2b1c},
+	{0x2b50, 0x2b50},
+	{0x2b55, 0x2b55},
+	{0x2e80, 0x303e},
+	{0x3041, 0x33ff},
+	{0x3400, 0x4dbf},
+	{0x4e00, 0x9fff},
+	{0xa000, 0xa4cf},
+	{0xa960, 0xa97f},
+	{0xac00, 0xd7a3},
+	{0xf900, 0xfaff},
+	{0xfe10, 0xfe19},
+	{0xfe30, 0xfe6f},
+	{0xff00, 0xff60},
+	{0xffe0, 0xffe6},
+	{0x16fe0, 0x16fe4},
+	{0x17000, 0x18cff},
+	{0x1b000, 0x1b2ff},
+	{0x1f004, 0x1f004},
+	{0x1f0cf, 0x1f0cf},
+	{0x1f18e, 0x1f18e},
+	{0x1f191, 0x1f19a},
+	{0x1f1e6, 0x1f1ff},
+	{0x1f200, 0x1f251},
+	{0x1f260, 0x1f265},
+	{0x1f300, 0x1f320},
+	{0x1f32d, 0x1f335},
+	{0x1f337, 0x1f37c},
+	{0x1f37e, 0x1f393},
+	{0x1f3a0, 0x1f3ca},
+	{0x1f3cf, 0x1f3d3},
+	{0x1f3e0, 0x1f3f0},
+	{0x1f3f4, 0x1f3f4},
+	{0x1f3f8, 0x1f43e},
+	{0x1f440, 0x1f440},
+	{0x1f442, 0x1f4fc},
+	{0x1f4ff, 0x1f53d},
+	{0x1f54b, 0x1f54e},
+	{0x1f550, 0x1f567},
+	{0x1f57a, 0x1f57a},
+	{0x1f595, 0x1f596},
+	{0x1f5a4, 0x1f5a4},
+	{0x1f5fb, 0x1f64f},
+	{0x1f680, 0x1f6c5},
+	{0x1f6cc, 0x1f6cc},
+	{0x1f6d0, 0x1f6d2},
+	{0x1f6d5, 0x1f6d7},
+	{0x1f6dc, 0x1f6df},
+	{0x1f6eb, 0x1f6ec},
+	{0x1f6f4, 0x1f6fc},
+	{0x1f7e0, 0x1f7eb},
+	{0x1f7f0, 0x1f7f0},
+	{0x1f90c, 0x1f93a},
+	{0x1f93c, 0x1f945},
+	{0x1f947, 0x1f9ff},
+	{0x1fa70, 0x1faff},
+	{0x20000, 0x2fffd},
+	{0x30000, 0x3fffd},
+}
+
+func isWide(r rune) bool {
+	for _, wide := range wideRanges {
+		if r < wide[0] {
+			return false
+		}
+		if r <= wide[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// isExtend reports whether r is displayed as part of the character before it,
+// e.g., a combining accent, a variation selector, or a skin tone modifier.
+func isExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r == zeroWidthJoiner ||
+		unicode.Is(unicode.Variation_Selector, r) ||
+		(r >= 0x1f3fb && r <= 0x1f3ff) || // emoji modifiers
+		(r >= 0xe0020 && r <= 0xe007f) // tags of flag sequences
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// Graphemes splits s into the characters that are displayed as one, e.g., e
+// followed by a combining acute accent, an emoji joined by zero width
+// joiners, or a flag of two regional indicators. It follows the extended
+// grapheme cluster rules of Unicode Standard Annex #29 that apply to names,
+// e.g., it does not join Hangul syllables from conjoining jamo.
+func Graphemes(s string) []string {
+	var graphemes []string
+	start := 0
+	var prev rune
+	regionalIndicators := 0
+	for i, r := range s {
+		if i > 0 {
+			joined := isExtend(r) ||
+				prev == zeroWidthJoiner ||
+				(prev == '\r' && r == '\n') ||
+				(isRegionalIndicator(prev) && isRegionalIndicator(r) && regionalIndicators%2 == 1)
+			if !joined {
+				graphemes = append(graphemes, s[start:i])
+				start = i
+			}
+		}
+		if isRegionalIndicator(r) {
+			regionalIndicators++
+		} else {
+			regionalIndicators = 0
+		}
+		prev = r
+	}
+	if start < len(s) {
+		graphemes = append(graphemes, s[start:])
+	}
+	return graphemes
+}
+
+// graphemeWidth returns the number of columns that the grapheme g is displayed
+// in. The width is that of the first character, or 2 if the character is
+// displayed as an emoji because it is followed by the emoji variation
+// selector.
+func graphemeWidth(g string) int {
+	r, _ := utf8.DecodeRuneInString(g)
+	switch {
+	case unicode.IsControl(r) || isExtend(r):
+		return 0
+	case isWide(r) || strings.ContainsRune(g, variationEmoji):
+		return 2
+	}
+	return 1
+}
+
+// Width returns the number of columns that s is displayed in by a terminal.
+func Width(s string) int {
+	width := 0
+	for _, g := range Graphemes(s) {
+		width += graphemeWidth(g)
+	}
+	return width
+}
+
+// TruncatePositions are the supported positions of Truncate.
+var TruncatePositions = []string{"start", "middle", "end"}
+
+// CheckTruncatePosition returns an error if position is not one of
+// TruncatePositions.
+func CheckTruncatePosition(position string) error {
+	if !slices.Contains(TruncatePositions, position) {
+		return fmt.Errorf("truncation position %s not supported, expected one of %s", position, strings.Join(TruncatePositions, ", "))
+	}
+	return nil
+}
+
+// Truncate returns s shortened to at most width columns by replacing the
+// characters at the start, middle, or end of s with ellipsis. Characters are
+// never split, so the result may be narrower than width. If ellipsis is wider
+// than width, s is shortened without it.
+func Truncate(s string, width int, position string, ellipsis string) (string, error) {
+	graphemes := Graphemes(s)
+	// take returns the graphemes, from the end if reversed, that fit in width
+	take := func(width int, reversed bool) string {
+		n, used := 0, 0
+		for n < len(graphemes) {
+			g := graphemes[n]
+			if reversed {
+				g = graphemes[len(graphemes)-1-n]
+			}
+			if used+graphemeWidth(g) > width {
+				break
+			}
+			used += graphemeWidth(g)
+			n++
+		}
+		if reversed {
+			return strings.Join(graphemes[len(graphemes)-n:], "")
+		}
+		return strings.Join(graphemes[:n], "")
+	}
+
+	if err := CheckTruncatePosition(position); err != nil {
+		return "", err
+	}
+	if Width(s) <= width {
+		return s, nil
+	}
+	available := width - Width(ellipsis)
+	if available < 0 {
+		available, ellipsis = width, ""
+	}
+
+	switch position {
+	case "start":
+		return ellipsis + take(available, true), nil
+	case "middle":
+		start := take((available+1)/2, false)
+		return start + ellipsis + take(available-Width(start), true), nil
+	}
+	return take(available, false) + ellipsis, nil
+}
+
+// AbbreviatePath returns the path p with each component but the last
+// shortened to its first character, e.g., f/PROJ-123 for feature/PROJ-123.
+func AbbreviatePath(p string) string {
+	components := strings.Split(p, "/")
+	for i, component := range components[:len(components)-1] {
+		if graphemes := Graphemes(component); len(graphemes) > 0 {
+			components[i] = graphemes[0]
+		}
+	}
+	return strings.Join(components, "/")
+}
@@ -0,0 +1,95 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected []string
+		width    int
+	}{
+		{"main", []string{"m", "a", "i", "n"}, 4},
+		{"cafe\u0301", []string{"c", "a", "f", "e\u0301"}, 4},
+		{"日本語", []string{"日", "本", "語"}, 6},
+		{"fix-🐛", []string{"f", "i", "x", "-", "🐛"}, 6},
+		{"👩‍💻!", []string{"👩‍💻", "!"}, 3},
+		{"👍🏽", []string{"👍🏽"}, 2},
+		{"🇯🇵🇫🇷x", []string{"🇯🇵", "🇫🇷", "x"}, 5},
+		{"✔️", []string{"✔️"}, 2},
+		{"", nil, 0},
+	}
+
+	for _, test := range tests {
+		actual := Graphemes(test.s)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Graphemes(%q): expected %q, got %q", test.s, test.expected, actual)
+		}
+		if width := Width(test.s); width != test.width {
+			t.Errorf("Width(%q): expected %d, got %d", test.s, test.width, width)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s        string
+		width    int
+		position string
+		ellipsis string
+		expected string
+		err      string
+	}{
+		{"feature/PROJ-12345", 18, "end", "…", "feature/PROJ-12345", ""},
+		{"feature/PROJ-12345", 10, "end", "…", "feature/P…", ""},
+		{"feature/PROJ-12345", 10, "start", "…", "…ROJ-12345", ""},
+		{"feature/PROJ-12345", 10, "middle", "…", "featu…2345", ""},
+		{"feature/PROJ-12345", 10, "middle", "...", "feat...345", ""},
+		{"feature/PROJ-12345", 2, "end", "...", "fe", ""},
+		{"日本語のブランチ", 7, "end", "…", "日本語…", ""},
+		{"日本語のブランチ", 7, "start", "…", "…ランチ", ""},
+		{"👩‍💻👩‍💻👩‍💻", 5, "end", "…", "👩‍💻👩‍💻…", ""},
+		{"cafe\u0301-cafe\u0301", 5, "start", "…", "…cafe\u0301", ""},
+		{"main", 2, "left", "…", "", "truncation position left not supported, expected one of start, middle, end"},
+	}
+
+	for _, test := range tests {
+		actual, err := Truncate(test.s, test.width, test.position, test.ellipsis)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("Truncate(%q, %d, %s): expected error %q, got %v", test.s, test.width, test.position, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Truncate(%q, %d, %s): unexpected error: %s", test.s, test.width, test.position, err)
+		}
+		if actual != test.expected {
+			t.Errorf("Truncate(%q, %d, %s): expected %q, got %q", test.s, test.width, test.position, test.expected, actual)
+		}
+		if Width(actual) > test.width {
+			t.Errorf("Truncate(%q, %d, %s): %q is wider than %d", test.s, test.width, test.position, actual, test.width)
+		}
+	}
+}
+
+func TestAbbreviatePath(t *testing.T) {
+	tests := []struct {
+		p        string
+		expected string
+	}{
+		{"main", "main"},
+		{"feature/PROJ-12345", "f/PROJ-12345"},
+		{"user/mike/feature/login", "u/m/f/login"},
+		{"émoji/👩‍💻/fix", "é/👩‍💻/fix"},
+		{"a//b/", "a//b/"},
+	}
+
+	for _, test := range tests {
+		if actual := AbbreviatePath(test.p); actual != test.expected {
+			t.Errorf("AbbreviatePath(%q): expected %q, got %q", test.p, test.expected, actual)
+		}
+	}
+}